@@ -2,6 +2,7 @@ package CacheServiceClient
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	service "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
@@ -16,11 +17,14 @@ const serviceName = "CacheService"
 
 type CacheServiceClient struct {
 	services.ServiceClientBase[service.CacheServiceClient]
+	// nil unless EnableNearCache was called
+	near *nearCache
 }
 
 func NewCacheServiceClient(addresses []string, registryClient *RegistryServiceClient.RegistryServiceClient) *CacheServiceClient {
 	return &CacheServiceClient{
 		ServiceClientBase: services.ServiceClientBase[service.CacheServiceClient]{
+			ServiceName:       serviceName,
 			RegistryAddresses: addresses,
 			CreateClient:      service.NewCacheServiceClient,
 			RegistryClient:    registryClient,
@@ -36,6 +40,17 @@ func NewCacheServiceClient(addresses []string, registryClient *RegistryServiceCl
 // }
 
 func (obj *CacheServiceClient) Set(key, value string) error {
+	return obj.SetBytes(key, []byte(value), 0)
+}
+
+// SetWithTTL stores a value that expires after ttl. A ttl of 0 keeps it until it is deleted.
+func (obj *CacheServiceClient) SetWithTTL(key, value string, ttl time.Duration) error {
+	return obj.SetBytes(key, []byte(value), ttl)
+}
+
+// SetBytes stores a binary value that expires after ttl, see SetWithTTL.
+// It fails with codes.InvalidArgument if the value is larger than the maximum value size of the service.
+func (obj *CacheServiceClient) SetBytes(key string, value []byte, ttl time.Duration) error {
 	c, closeFunc, err := obj.Connect(serviceName)
 	if err != nil {
 		return err
@@ -43,27 +58,115 @@ func (obj *CacheServiceClient) Set(key, value string) error {
 	defer closeFunc()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
+	// after the call, so a concurrent Get doesn't fill the old value
+	defer obj.changed(key)
 
-	req := &service.StoreKeyValue{Key: key, Value: value}
+	req := &service.StoreKeyValue{Key: key, Value: value, TtlMs: ttl.Milliseconds()}
 	_, err = c.Set(ctx, req)
 	return err
 }
 
 func (obj *CacheServiceClient) Get(key string) (string, error) {
+	value, err := obj.GetBytes(key)
+	return string(value), err
+}
+
+// GetBytes returns the binary value of a key, or nil if it is missing or expired.
+// The value is read from the near cache, if it is enabled and has it.
+func (obj *CacheServiceClient) GetBytes(key string) ([]byte, error) {
+	if obj.near != nil {
+		if value, ok := obj.near.get(key); ok {
+			return value, nil
+		}
+	}
+	resp, err := obj.GetVersioned(key)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// GetVersioned returns the value of a key and its version, to change it with CompareAndSet.
+// Found is false if the key is missing or expired. It is always read from the service.
+func (obj *CacheServiceClient) GetVersioned(key string) (*service.VersionedValue, error) {
 	c, closeFunc, err := obj.Connect(serviceName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer closeFunc()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
+	var generation uint64
+	if obj.near != nil {
+		generation = obj.near.tier.Generation()
+	}
 	req := &wrapperspb.StringValue{Value: key}
 	resp, err := c.Get(ctx, req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return resp.Value, nil
+	if obj.near != nil {
+		obj.near.fill(key, resp, generation)
+	}
+	return resp, nil
+}
+
+// changed drops keys the client changes from the near cache, without waiting for their events.
+func (obj *CacheServiceClient) changed(keys ...string) {
+	if obj.near == nil {
+		return
+	}
+	for _, key := range keys {
+		obj.near.tier.Invalidate(key)
+	}
+}
+
+// CompareAndSet stores a value if the key has the expected version, or is missing if expectedVersion is 0,
+// and returns its new version. It fails with codes.FailedPrecondition if the key has another version.
+//
+//	for {
+//		current, err := client.GetVersioned(key)
+//		...
+//		_, err = client.CompareAndSet(key, current.Version, update(current.Value), 0)
+//		if status.Code(err) != codes.FailedPrecondition {
+//			return err
+//		}
+//	}
+func (obj *CacheServiceClient) CompareAndSet(key string, expectedVersion int64, value []byte, ttl time.Duration) (int64, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer obj.changed(key)
+
+	req := &service.CompareAndSetRequest{Key: key, ExpectedVersion: expectedVersion, Value: value, TtlMs: ttl.Milliseconds()}
+	resp, err := c.CompareAndSet(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetValue(), nil
+}
+
+// SetIfAbsent stores a value if the key is missing or expired, and returns false if it exists.
+func (obj *CacheServiceClient) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return false, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer obj.changed(key)
+
+	resp, err := c.SetIfAbsent(ctx, &service.StoreKeyValue{Key: key, Value: value, TtlMs: ttl.Milliseconds()})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetValue(), nil
 }
 
 func (obj *CacheServiceClient) Delete(key string) error {
@@ -74,6 +177,7 @@ func (obj *CacheServiceClient) Delete(key string) error {
 	defer closeFunc()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
+	defer obj.changed(key)
 
 	req := &wrapperspb.StringValue{Value: key}
 	_, err = c.Delete(ctx, req)
@@ -95,3 +199,245 @@ func (obj *CacheServiceClient) IsAlive() (bool, error) {
 	}
 	return resp.GetValue(), nil
 }
+
+// Incr adds 1 to the integer value of a key and returns the new value. A missing key counts as 0,
+// and is created with ttl. A ttl of 0 keeps it until it is deleted.
+func (obj *CacheServiceClient) Incr(key string, ttl time.Duration) (int64, error) {
+	return obj.IncrBy(key, 1, ttl)
+}
+
+// Decr subtracts 1 from the integer value of a key and returns the new value, see Incr.
+func (obj *CacheServiceClient) Decr(key string, ttl time.Duration) (int64, error) {
+	return obj.IncrBy(key, -1, ttl)
+}
+
+// IncrBy adds delta to the integer value of a key and returns the new value, see Incr.
+// It fails with codes.FailedPrecondition if the value isn't an integer or the result overflows.
+func (obj *CacheServiceClient) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer obj.changed(key)
+
+	resp, err := c.IncrBy(ctx, &service.CounterRequest{Key: key, Delta: delta, TtlMs: ttl.Milliseconds()})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetValue(), nil
+}
+
+// Expire sets the time to live of a key, and returns false if the key doesn't exist.
+func (obj *CacheServiceClient) Expire(key string, ttl time.Duration) (bool, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return false, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer obj.changed(key)
+
+	resp, err := c.Expire(ctx, &service.ExpireRequest{Key: key, TtlMs: ttl.Milliseconds()})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetValue(), nil
+}
+
+// TTL returns the remaining time to live of a key. It is -1ms if the key doesn't expire
+// and -2ms if it doesn't exist.
+func (obj *CacheServiceClient) TTL(key string) (time.Duration, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := c.TTL(ctx, &wrapperspb.StringValue{Value: key})
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.GetValue()) * time.Millisecond, nil
+}
+
+// Persist removes the time to live of a key, and returns false if the key doesn't exist or didn't expire.
+func (obj *CacheServiceClient) Persist(key string) (bool, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return false, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer obj.changed(key)
+
+	resp, err := c.Persist(ctx, &wrapperspb.StringValue{Value: key})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetValue(), nil
+}
+
+// MGet reads many keys in one call. Each key has a result, in the order of keys, with its value
+// or why it failed.
+func (obj *CacheServiceClient) MGet(keys []string) ([]*service.KeyResult, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := c.MGet(ctx, &service.Keys{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// MSet stores many values in one call, all with the same ttl. A ttl of 0 keeps them until they are deleted.
+// Each key has a result telling why it failed, if it did, in the order of the sorted keys.
+func (obj *CacheServiceClient) MSet(values map[string][]byte, ttl time.Duration) ([]*service.KeyResult, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer func() {
+		for key := range values {
+			obj.changed(key)
+		}
+	}()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	req := &service.MSetRequest{}
+	for _, key := range keys {
+		req.Entries = append(req.Entries, &service.StoreKeyValue{Key: key, Value: values[key], TtlMs: ttl.Milliseconds()})
+	}
+	resp, err := c.MSet(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// MDelete deletes many keys in one call. Each key has a result telling why it failed, if it did.
+func (obj *CacheServiceClient) MDelete(keys []string) ([]*service.KeyResult, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer obj.changed(keys...)
+
+	resp, err := c.MDelete(ctx, &service.Keys{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// Scan returns a page of up to limit keys starting with prefix, with their values if withValues is set,
+// and the cursor of the next page. Pass an empty cursor for the first page. The cursor is empty after the last page.
+func (obj *CacheServiceClient) Scan(prefix, cursor string, limit int, withValues bool) ([]*service.KeyResult, string, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return nil, "", err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := c.Scan(ctx, &service.ScanRequest{Prefix: prefix, Cursor: cursor, Limit: int32(limit), WithValues: withValues})
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Keys, resp.NextCursor, nil
+}
+
+// ScanAll returns all the keys starting with prefix, reading them page by page.
+func (obj *CacheServiceClient) ScanAll(prefix string, withValues bool) ([]*service.KeyResult, error) {
+	var keys []*service.KeyResult
+	cursor := ""
+	for {
+		page, next, err := obj.Scan(prefix, cursor, 0, withValues)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+		if next == "" {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+// Subscribe calls handler with the events of the keys matching pattern, changed by any node, until ctx is
+// canceled or the stream breaks. * in pattern matches any characters and ? any one, and an empty pattern
+// matches all keys. It returns nil once ctx is canceled. A subscriber that falls behind gets
+// codes.ResourceExhausted, and should read the keys it follows again before it subscribes again.
+func (obj *CacheServiceClient) Subscribe(ctx context.Context, pattern string, handler func(event *service.KeyEvent)) error {
+	return obj.subscribe(ctx, pattern, nil, handler)
+}
+
+// subscribe calls started once the service streams the events, if it isn't nil, see Subscribe.
+func (obj *CacheServiceClient) subscribe(ctx context.Context, pattern string, started func(), handler func(event *service.KeyEvent)) error {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	stream, err := c.Subscribe(ctx, &service.SubscribeRequest{KeyPattern: pattern})
+	if err != nil {
+		return err
+	}
+	if started != nil {
+		// the service sends the headers once it subscribed
+		if _, err := stream.Header(); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		started()
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		handler(event)
+	}
+}
+
+// LocalStats returns the statistics of the local tier of a node of the service.
+func (obj *CacheServiceClient) LocalStats() (*service.LocalTierStats, error) {
+	c, closeFunc, err := obj.Connect(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFunc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return c.LocalStats(ctx, &emptypb.Empty{})
+}
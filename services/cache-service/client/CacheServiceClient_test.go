@@ -3,10 +3,12 @@ package CacheServiceClient
 import (
 	"context"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
 	common "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common" // Adjust this path as needed
+	CacheServiceServant "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/servant"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -25,7 +27,7 @@ func TestCacheServiceClient(t *testing.T) {
 
 	// Create a mock server
 	mockServer := grpc.NewServer()
-	service := &MockCacheService{data: make(map[string]string)}
+	service := &MockCacheService{data: make(map[string][]byte), batch: CacheServiceServant.NewCache(&mapRing{data: make(map[string]string)})}
 	common.RegisterCacheServiceServer(mockServer, service)
 
 	// Start the mock server in a goroutine
@@ -54,7 +56,7 @@ func TestCacheServiceClient(t *testing.T) {
 
 	// Test Set method
 	t.Run("Set", func(t *testing.T) {
-		req := &common.StoreKeyValue{Key: "testKey", Value: "testValue"}
+		req := &common.StoreKeyValue{Key: "testKey", Value: []byte("testValue")}
 		_, err := client.Set(context.Background(), req)
 		if err != nil {
 			t.Fatalf("Set() failed: %v", err)
@@ -68,7 +70,7 @@ func TestCacheServiceClient(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Get() failed: %v", err)
 		}
-		if res.Value != "testValue" {
+		if string(res.Value) != "testValue" {
 			t.Errorf("Get() returned wrong value: got %v, want %v", res.Value, "testValue")
 		}
 	})
@@ -82,6 +84,71 @@ func TestCacheServiceClient(t *testing.T) {
 		}
 	})
 
+	// Test MSet, MGet and MDelete methods
+	t.Run("MSetMGet", func(t *testing.T) {
+		reserved := CacheServiceServant.ChunkKeyPrefix + "a"
+		set, err := client.MSet(context.Background(), &common.MSetRequest{Entries: []*common.StoreKeyValue{
+			{Key: "a", Value: []byte("1")},
+			{Key: "b", Value: []byte{0, 0xff}},
+			{Key: reserved, Value: []byte("2")},
+			{Key: "c", Value: []byte("3"), TtlMs: -1},
+		}})
+		if err != nil {
+			t.Fatalf("MSet() failed: %v", err)
+		}
+		results := resultsByKey(t, set.Results, "a", "b", reserved, "c")
+		if results["a"].Error != "" || results["b"].Error != "" {
+			t.Errorf("MSet() failed to set a and b: %v", set.Results)
+		}
+		if results[reserved].Error != CacheServiceServant.ErrReservedKey.Error() || results["c"].Error != CacheServiceServant.ErrInvalidTTL.Error() {
+			t.Errorf("MSet() returned wrong errors: %v", set.Results)
+		}
+
+		got, err := client.MGet(context.Background(), &common.Keys{Keys: []string{"a", "missing", "b", "c"}})
+		if err != nil {
+			t.Fatalf("MGet() failed: %v", err)
+		}
+		results = resultsByKey(t, got.Results, "a", "missing", "b", "c")
+		if a := results["a"]; !a.Found || string(a.Value) != "1" || a.Version == 0 {
+			t.Errorf("MGet() returned wrong result for a: %v", a)
+		}
+		if b := results["b"]; !b.Found || string(b.Value) != "\x00\xff" || b.Version == 0 {
+			t.Errorf("MGet() returned wrong result for b: %v", b)
+		}
+		if results["missing"].Found || results["c"].Found || results["missing"].Error != "" {
+			t.Errorf("MGet() found keys that weren't set: %v", got.Results)
+		}
+
+		deleted, err := client.MDelete(context.Background(), &common.Keys{Keys: []string{"a", "missing"}})
+		if err != nil {
+			t.Fatalf("MDelete() failed: %v", err)
+		}
+		for _, result := range deleted.Results {
+			if result.Error != "" {
+				t.Errorf("MDelete() failed for %v: %v", result.Key, result.Error)
+			}
+		}
+		got, _ = client.MGet(context.Background(), &common.Keys{Keys: []string{"a", "b"}})
+		if results = resultsByKey(t, got.Results, "a", "b"); results["a"].Found || !results["b"].Found {
+			t.Errorf("MGet() after MDelete(a) returned %v", got.Results)
+		}
+	})
+
+	// Test Subscribe method
+	t.Run("Subscribe", func(t *testing.T) {
+		stream, err := client.Subscribe(context.Background(), &common.SubscribeRequest{KeyPattern: "test*"})
+		if err != nil {
+			t.Fatalf("Subscribe() failed: %v", err)
+		}
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+		if event.Type != common.KeyEventType_KEY_SET || event.Key != "testKey" {
+			t.Errorf("Subscribe() returned wrong event: %v", event)
+		}
+	})
+
 	// Test IsAlive method
 	t.Run("IsAlive", func(t *testing.T) {
 		_, err := client.IsAlive(context.Background(), &emptypb.Empty{})
@@ -91,10 +158,65 @@ func TestCacheServiceClient(t *testing.T) {
 	})
 }
 
-// Mock implementation of CacheServiceServer
+// resultsByKey indexes the results of a batch call, and fails unless there is one result per key.
+func resultsByKey(t *testing.T, results []*common.KeyResult, keys ...string) map[string]*common.KeyResult {
+	t.Helper()
+	byKey := make(map[string]*common.KeyResult)
+	for _, result := range results {
+		byKey[result.Key] = result
+	}
+	for _, key := range keys {
+		if byKey[key] == nil {
+			t.Fatalf("no result for %v in %v", key, results)
+		}
+	}
+	if len(results) != len(keys) {
+		t.Fatalf("got %v results, want %v: %v", len(results), len(keys), results)
+	}
+	return byKey
+}
+
+// mapRing is an in-memory CacheServiceServant.Ring
+type mapRing struct {
+	mutex sync.Mutex
+	data  map[string]string
+}
+
+func (r *mapRing) Set(key string, value string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.data[key] = value
+	return nil
+}
+
+func (r *mapRing) Get(key string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.data[key], nil
+}
+
+func (r *mapRing) Delete(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.data, key)
+	return nil
+}
+
+func (r *mapRing) GetAllKeys() ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	keys := make([]string, 0, len(r.data))
+	for key := range r.data {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Mock implementation of CacheServiceServer. Batch calls run on a servant cache
 type MockCacheService struct {
 	common.UnimplementedCacheServiceServer
-	data map[string]string
+	data  map[string][]byte
+	batch *CacheServiceServant.Cache
 }
 
 func (m *MockCacheService) Set(ctx context.Context, req *common.StoreKeyValue) (*emptypb.Empty, error) {
@@ -102,12 +224,12 @@ func (m *MockCacheService) Set(ctx context.Context, req *common.StoreKeyValue) (
 	return &emptypb.Empty{}, nil
 }
 
-func (m *MockCacheService) Get(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+func (m *MockCacheService) Get(ctx context.Context, req *wrapperspb.StringValue) (*common.VersionedValue, error) {
 	val, exists := m.data[req.Value]
 	if !exists {
 		return nil, status.Errorf(codes.NotFound, "key not found")
 	}
-	return &wrapperspb.StringValue{Value: val}, nil
+	return &common.VersionedValue{Value: val, Found: true}, nil
 }
 
 func (m *MockCacheService) Delete(ctx context.Context, req *wrapperspb.StringValue) (*emptypb.Empty, error) {
@@ -115,6 +237,22 @@ func (m *MockCacheService) Delete(ctx context.Context, req *wrapperspb.StringVal
 	return &emptypb.Empty{}, nil
 }
 
+func (m *MockCacheService) MGet(ctx context.Context, req *common.Keys) (*common.KeyResults, error) {
+	return &common.KeyResults{Results: m.batch.MGet(req.Keys)}, nil
+}
+
+func (m *MockCacheService) MSet(ctx context.Context, req *common.MSetRequest) (*common.KeyResults, error) {
+	return &common.KeyResults{Results: m.batch.MSet(req.Entries)}, nil
+}
+
+func (m *MockCacheService) MDelete(ctx context.Context, req *common.Keys) (*common.KeyResults, error) {
+	return &common.KeyResults{Results: m.batch.MDelete(req.Keys)}, nil
+}
+
+func (m *MockCacheService) Subscribe(req *common.SubscribeRequest, stream common.CacheService_SubscribeServer) error {
+	return stream.Send(&common.KeyEvent{Type: common.KeyEventType_KEY_SET, Key: "testKey", Value: []byte("testValue")})
+}
+
 func (m *MockCacheService) IsAlive(ctx context.Context, _ *emptypb.Empty) (*wrapperspb.BoolValue, error) {
 	return &wrapperspb.BoolValue{Value: true}, nil
 }
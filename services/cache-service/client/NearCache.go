@@ -0,0 +1,132 @@
+package CacheServiceClient
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	service "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	CacheServiceServant "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/servant"
+)
+
+const (
+	// how long values are read from the near cache when the config doesn't say
+	defaultNearCacheTTL = 10 * time.Second
+	// how long the near cache waits to subscribe again after its subscription broke
+	nearCacheRetryInterval = time.Second
+)
+
+type NearCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// how long a value is read from the near cache before it is read from the service again, in milliseconds
+	TTLMs int `yaml:"ttlMs"`
+	// eviction policy and bounds, as in the local tier of the service
+	Policy     string `yaml:"policy"`
+	MaxEntries int    `yaml:"maxEntries"`
+	MaxBytes   int64  `yaml:"maxBytes"`
+}
+
+// nearCache keeps the values a client read in memory. It subscribes to the changes of all keys,
+// and drops the keys that change. It is only used while the subscription runs: events may be
+// missed otherwise, so the near cache is cleared whenever the subscription breaks.
+type nearCache struct {
+	tier       *CacheServiceServant.LocalTier
+	ttl        time.Duration
+	subscribed atomic.Bool
+	cancel     context.CancelFunc
+}
+
+// EnableNearCache keeps the values Get reads in memory, for up to the TTL of config. Values changed by any
+// client are dropped as soon as the service streams their change. Call it before the client is used.
+func (obj *CacheServiceClient) EnableNearCache(config NearCacheConfig) error {
+	tier, err := CacheServiceServant.NewLocalTier(CacheServiceServant.LocalTierConfig{
+		Enabled:    true,
+		Policy:     config.Policy,
+		MaxEntries: config.MaxEntries,
+		MaxBytes:   config.MaxBytes,
+	})
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(config.TTLMs) * time.Millisecond
+	if ttl <= 0 {
+		ttl = defaultNearCacheTTL
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	obj.near = &nearCache{tier: tier, ttl: ttl, cancel: cancel}
+	go obj.near.follow(ctx, obj)
+	return nil
+}
+
+// NearCacheStats returns the statistics of the near cache, or nil if it isn't enabled.
+func (obj *CacheServiceClient) NearCacheStats() *service.LocalTierStats {
+	if obj.near == nil {
+		return nil
+	}
+	return obj.near.tier.Stats()
+}
+
+// Close stops the near cache.
+func (obj *CacheServiceClient) Close() {
+	if obj.near != nil {
+		obj.near.cancel()
+	}
+}
+
+// follow drops the keys the service streams the changes of, and subscribes again when the stream breaks.
+func (n *nearCache) follow(ctx context.Context, obj *CacheServiceClient) {
+	for {
+		err := obj.subscribe(ctx, "", func() { n.subscribed.Store(true) }, n.changed)
+		n.subscribed.Store(false)
+		n.tier.Clear()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Near cache subscription broke, subscribing again: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nearCacheRetryInterval):
+		}
+	}
+}
+
+// changed drops a key the service streamed the change of. The value of the event isn't stored:
+// chunked events don't carry it, and an empty value would be served in its place.
+func (n *nearCache) changed(event *service.KeyEvent) {
+	n.tier.Invalidate(event.Key)
+}
+
+// get returns the value of a key, and false if it isn't in the near cache.
+func (n *nearCache) get(key string) ([]byte, bool) {
+	if !n.subscribed.Load() {
+		return nil, false
+	}
+	entry, ok := n.tier.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if time.Now().UnixMilli() >= entry.ExpiresAt {
+		n.tier.Invalidate(key)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// fill adds a value read from the service, unless a key changed since generation was read.
+// The value is kept for the TTL of the near cache, and not after the key expires.
+func (n *nearCache) fill(key string, value *service.VersionedValue, generation uint64) {
+	if !value.Found || !n.subscribed.Load() {
+		return
+	}
+	expiresAt := time.Now().Add(n.ttl).UnixMilli()
+	if value.ExpiresAt != 0 {
+		expiresAt = min(expiresAt, value.ExpiresAt)
+	}
+	n.tier.Fill(key, &service.CacheEntry{
+		Value:     value.Value,
+		Version:   value.Version,
+		ExpiresAt: expiresAt,
+	}, generation)
+}
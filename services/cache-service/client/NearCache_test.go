@@ -0,0 +1,62 @@
+package CacheServiceClient
+
+import (
+	"testing"
+	"time"
+
+	common "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	CacheServiceServant "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/servant"
+)
+
+func TestNearCache(t *testing.T) {
+	tier, err := CacheServiceServant.NewLocalTier(CacheServiceServant.LocalTierConfig{MaxEntries: 10})
+	if err != nil {
+		t.Fatalf("NewLocalTier() failed: %v", err)
+	}
+	near := &nearCache{tier: tier, ttl: 50 * time.Millisecond}
+	value := &common.VersionedValue{Value: []byte("1"), Version: 1, Found: true}
+
+	// events may be missed before the subscription runs
+	near.fill("a", value, tier.Generation())
+	if _, ok := near.get("a"); ok {
+		t.Errorf("get(a) hit before the subscription ran")
+	}
+
+	near.subscribed.Store(true)
+	near.fill("a", value, tier.Generation())
+	if cached, ok := near.get("a"); !ok || string(cached) != "1" {
+		t.Errorf("get(a) = %q, %v, want 1", cached, ok)
+	}
+
+	// the key changed while it was read
+	generation := tier.Generation()
+	tier.Invalidate("b")
+	near.fill("b", value, generation)
+	if _, ok := near.get("b"); ok {
+		t.Errorf("a stale value was filled")
+	}
+
+	// keys set to values too large to stream are dropped, not set to an empty value
+	near.fill("c", value, tier.Generation())
+	near.changed(&common.KeyEvent{Type: common.KeyEventType_KEY_SET, Key: "c", Version: 2, Chunked: true})
+	if cached, ok := near.get("c"); ok {
+		t.Errorf("get(c) = %q after a chunked set, want a miss", cached)
+	}
+
+	// keys expiring before the ttl are dropped when they expire
+	expiring := &common.VersionedValue{Value: []byte("2"), Version: 1, Found: true, ExpiresAt: time.Now().Add(-time.Millisecond).UnixMilli()}
+	near.fill("expiring", expiring, tier.Generation())
+	if _, ok := near.get("expiring"); ok {
+		t.Errorf("get(expiring) hit after the key expired")
+	}
+
+	near.fill("missing", &common.VersionedValue{}, tier.Generation())
+	if _, ok := near.get("missing"); ok {
+		t.Errorf("a missing key was filled")
+	}
+
+	time.Sleep(near.ttl)
+	if _, ok := near.get("a"); ok {
+		t.Errorf("get(a) hit after the ttl")
+	}
+}
@@ -21,10 +21,24 @@ import (
 const _ = grpc.SupportPackageIsVersion8
 
 const (
-	CacheService_Set_FullMethodName     = "/cacheservice.CacheService/Set"
-	CacheService_Get_FullMethodName     = "/cacheservice.CacheService/Get"
-	CacheService_Delete_FullMethodName  = "/cacheservice.CacheService/Delete"
-	CacheService_IsAlive_FullMethodName = "/cacheservice.CacheService/IsAlive"
+	CacheService_Set_FullMethodName           = "/cacheservice.CacheService/Set"
+	CacheService_Get_FullMethodName           = "/cacheservice.CacheService/Get"
+	CacheService_Delete_FullMethodName        = "/cacheservice.CacheService/Delete"
+	CacheService_IsAlive_FullMethodName       = "/cacheservice.CacheService/IsAlive"
+	CacheService_CompareAndSet_FullMethodName = "/cacheservice.CacheService/CompareAndSet"
+	CacheService_SetIfAbsent_FullMethodName   = "/cacheservice.CacheService/SetIfAbsent"
+	CacheService_Incr_FullMethodName          = "/cacheservice.CacheService/Incr"
+	CacheService_Decr_FullMethodName          = "/cacheservice.CacheService/Decr"
+	CacheService_IncrBy_FullMethodName        = "/cacheservice.CacheService/IncrBy"
+	CacheService_Expire_FullMethodName        = "/cacheservice.CacheService/Expire"
+	CacheService_TTL_FullMethodName           = "/cacheservice.CacheService/TTL"
+	CacheService_Persist_FullMethodName       = "/cacheservice.CacheService/Persist"
+	CacheService_MGet_FullMethodName          = "/cacheservice.CacheService/MGet"
+	CacheService_MSet_FullMethodName          = "/cacheservice.CacheService/MSet"
+	CacheService_MDelete_FullMethodName       = "/cacheservice.CacheService/MDelete"
+	CacheService_Subscribe_FullMethodName     = "/cacheservice.CacheService/Subscribe"
+	CacheService_Scan_FullMethodName          = "/cacheservice.CacheService/Scan"
+	CacheService_LocalStats_FullMethodName    = "/cacheservice.CacheService/LocalStats"
 )
 
 // CacheServiceClient is the client API for CacheService service.
@@ -33,14 +47,49 @@ const (
 //
 // Define the CacheService service
 type CacheServiceClient interface {
-	// Stores a key/value pair in the cache
+	// Stores a key/value pair in the cache. Fails with INVALID_ARGUMENT if the value is larger than the maximum value size
 	Set(ctx context.Context, in *StoreKeyValue, opts ...grpc.CallOption) (*empty.Empty, error)
-	// Retrieves the value for a given key from the cache
-	Get(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.StringValue, error)
+	// Retrieves the value for a given key from the cache, and its version
+	Get(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*VersionedValue, error)
 	// Deletes a key/value pair from the cache
 	Delete(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*empty.Empty, error)
 	// Checks if the service is alive
 	IsAlive(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*wrappers.BoolValue, error)
+	// Stores a value if the key has the expected version, and returns its new version.
+	// Fails with FAILED_PRECONDITION if the key has another version
+	CompareAndSet(ctx context.Context, in *CompareAndSetRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error)
+	// Stores a value if the key is missing or expired. Returns false if it exists
+	SetIfAbsent(ctx context.Context, in *StoreKeyValue, opts ...grpc.CallOption) (*wrappers.BoolValue, error)
+	// Adds 1 to the integer value of a key, a missing key being 0, and returns the new value
+	Incr(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error)
+	// Subtracts 1 from the integer value of a key, a missing key being 0, and returns the new value
+	Decr(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error)
+	// Adds delta to the integer value of a key, a missing key being 0, and returns the new value.
+	// Fails with FAILED_PRECONDITION if the value isn't an integer or the result overflows
+	IncrBy(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error)
+	// Sets the time to live of a key. Returns false if the key doesn't exist
+	Expire(ctx context.Context, in *ExpireRequest, opts ...grpc.CallOption) (*wrappers.BoolValue, error)
+	// Returns the remaining time to live of a key in milliseconds, -1 if it doesn't expire and -2 if it doesn't exist
+	TTL(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.Int64Value, error)
+	// Removes the time to live of a key. Returns false if the key doesn't exist or didn't expire
+	Persist(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.BoolValue, error)
+	// Retrieves the values of many keys. Missing and expired keys aren't found
+	MGet(ctx context.Context, in *Keys, opts ...grpc.CallOption) (*KeyResults, error)
+	// Stores many key/value pairs
+	MSet(ctx context.Context, in *MSetRequest, opts ...grpc.CallOption) (*KeyResults, error)
+	// Deletes many keys
+	MDelete(ctx context.Context, in *Keys, opts ...grpc.CallOption) (*KeyResults, error)
+	// Streams the events of the keys matching a pattern, changed by any node, until the call is canceled.
+	// Fails with RESOURCE_EXHAUSTED if the subscriber falls behind, and should then read the keys again
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (CacheService_SubscribeClient, error)
+	// Returns a page of the keys starting with a prefix. Keys deleted or expired before their page is read are
+	// skipped, and keys set while the pages are read may or may not be returned.
+	// A page costs reading every key of the ring and sorting them. The node keeps the keys left after the
+	// next cursor for 30 seconds, so the next page sent to it within them only reads the keys it returns.
+	// Keys set after the keys were kept aren't returned by the pages read from them
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+	// Returns the statistics of the local memory tier of the node serving the call
+	LocalStats(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*LocalTierStats, error)
 }
 
 type cacheServiceClient struct {
@@ -61,9 +110,9 @@ func (c *cacheServiceClient) Set(ctx context.Context, in *StoreKeyValue, opts ..
 	return out, nil
 }
 
-func (c *cacheServiceClient) Get(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.StringValue, error) {
+func (c *cacheServiceClient) Get(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*VersionedValue, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(wrappers.StringValue)
+	out := new(VersionedValue)
 	err := c.cc.Invoke(ctx, CacheService_Get_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -91,20 +140,218 @@ func (c *cacheServiceClient) IsAlive(ctx context.Context, in *empty.Empty, opts
 	return out, nil
 }
 
+func (c *cacheServiceClient) CompareAndSet(ctx context.Context, in *CompareAndSetRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.Int64Value)
+	err := c.cc.Invoke(ctx, CacheService_CompareAndSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) SetIfAbsent(ctx context.Context, in *StoreKeyValue, opts ...grpc.CallOption) (*wrappers.BoolValue, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.BoolValue)
+	err := c.cc.Invoke(ctx, CacheService_SetIfAbsent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Incr(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.Int64Value)
+	err := c.cc.Invoke(ctx, CacheService_Incr_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Decr(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.Int64Value)
+	err := c.cc.Invoke(ctx, CacheService_Decr_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) IncrBy(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*wrappers.Int64Value, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.Int64Value)
+	err := c.cc.Invoke(ctx, CacheService_IncrBy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Expire(ctx context.Context, in *ExpireRequest, opts ...grpc.CallOption) (*wrappers.BoolValue, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.BoolValue)
+	err := c.cc.Invoke(ctx, CacheService_Expire_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) TTL(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.Int64Value, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.Int64Value)
+	err := c.cc.Invoke(ctx, CacheService_TTL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Persist(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*wrappers.BoolValue, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(wrappers.BoolValue)
+	err := c.cc.Invoke(ctx, CacheService_Persist_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) MGet(ctx context.Context, in *Keys, opts ...grpc.CallOption) (*KeyResults, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyResults)
+	err := c.cc.Invoke(ctx, CacheService_MGet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) MSet(ctx context.Context, in *MSetRequest, opts ...grpc.CallOption) (*KeyResults, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyResults)
+	err := c.cc.Invoke(ctx, CacheService_MSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) MDelete(ctx context.Context, in *Keys, opts ...grpc.CallOption) (*KeyResults, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KeyResults)
+	err := c.cc.Invoke(ctx, CacheService_MDelete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (CacheService_SubscribeClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CacheService_ServiceDesc.Streams[0], CacheService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cacheServiceSubscribeClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CacheService_SubscribeClient interface {
+	Recv() (*KeyEvent, error)
+	grpc.ClientStream
+}
+
+type cacheServiceSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *cacheServiceSubscribeClient) Recv() (*KeyEvent, error) {
+	m := new(KeyEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cacheServiceClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, CacheService_Scan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) LocalStats(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*LocalTierStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LocalTierStats)
+	err := c.cc.Invoke(ctx, CacheService_LocalStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CacheServiceServer is the server API for CacheService service.
 // All implementations must embed UnimplementedCacheServiceServer
 // for forward compatibility
 //
 // Define the CacheService service
 type CacheServiceServer interface {
-	// Stores a key/value pair in the cache
+	// Stores a key/value pair in the cache. Fails with INVALID_ARGUMENT if the value is larger than the maximum value size
 	Set(context.Context, *StoreKeyValue) (*empty.Empty, error)
-	// Retrieves the value for a given key from the cache
-	Get(context.Context, *wrappers.StringValue) (*wrappers.StringValue, error)
+	// Retrieves the value for a given key from the cache, and its version
+	Get(context.Context, *wrappers.StringValue) (*VersionedValue, error)
 	// Deletes a key/value pair from the cache
 	Delete(context.Context, *wrappers.StringValue) (*empty.Empty, error)
 	// Checks if the service is alive
 	IsAlive(context.Context, *empty.Empty) (*wrappers.BoolValue, error)
+	// Stores a value if the key has the expected version, and returns its new version.
+	// Fails with FAILED_PRECONDITION if the key has another version
+	CompareAndSet(context.Context, *CompareAndSetRequest) (*wrappers.Int64Value, error)
+	// Stores a value if the key is missing or expired. Returns false if it exists
+	SetIfAbsent(context.Context, *StoreKeyValue) (*wrappers.BoolValue, error)
+	// Adds 1 to the integer value of a key, a missing key being 0, and returns the new value
+	Incr(context.Context, *CounterRequest) (*wrappers.Int64Value, error)
+	// Subtracts 1 from the integer value of a key, a missing key being 0, and returns the new value
+	Decr(context.Context, *CounterRequest) (*wrappers.Int64Value, error)
+	// Adds delta to the integer value of a key, a missing key being 0, and returns the new value.
+	// Fails with FAILED_PRECONDITION if the value isn't an integer or the result overflows
+	IncrBy(context.Context, *CounterRequest) (*wrappers.Int64Value, error)
+	// Sets the time to live of a key. Returns false if the key doesn't exist
+	Expire(context.Context, *ExpireRequest) (*wrappers.BoolValue, error)
+	// Returns the remaining time to live of a key in milliseconds, -1 if it doesn't expire and -2 if it doesn't exist
+	TTL(context.Context, *wrappers.StringValue) (*wrappers.Int64Value, error)
+	// Removes the time to live of a key. Returns false if the key doesn't exist or didn't expire
+	Persist(context.Context, *wrappers.StringValue) (*wrappers.BoolValue, error)
+	// Retrieves the values of many keys. Missing and expired keys aren't found
+	MGet(context.Context, *Keys) (*KeyResults, error)
+	// Stores many key/value pairs
+	MSet(context.Context, *MSetRequest) (*KeyResults, error)
+	// Deletes many keys
+	MDelete(context.Context, *Keys) (*KeyResults, error)
+	// Streams the events of the keys matching a pattern, changed by any node, until the call is canceled.
+	// Fails with RESOURCE_EXHAUSTED if the subscriber falls behind, and should then read the keys again
+	Subscribe(*SubscribeRequest, CacheService_SubscribeServer) error
+	// Returns a page of the keys starting with a prefix. Keys deleted or expired before their page is read are
+	// skipped, and keys set while the pages are read may or may not be returned.
+	// A page costs reading every key of the ring and sorting them. The node keeps the keys left after the
+	// next cursor for 30 seconds, so the next page sent to it within them only reads the keys it returns.
+	// Keys set after the keys were kept aren't returned by the pages read from them
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
+	// Returns the statistics of the local memory tier of the node serving the call
+	LocalStats(context.Context, *empty.Empty) (*LocalTierStats, error)
 	mustEmbedUnimplementedCacheServiceServer()
 }
 
@@ -115,7 +362,7 @@ type UnimplementedCacheServiceServer struct {
 func (UnimplementedCacheServiceServer) Set(context.Context, *StoreKeyValue) (*empty.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
 }
-func (UnimplementedCacheServiceServer) Get(context.Context, *wrappers.StringValue) (*wrappers.StringValue, error) {
+func (UnimplementedCacheServiceServer) Get(context.Context, *wrappers.StringValue) (*VersionedValue, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
 func (UnimplementedCacheServiceServer) Delete(context.Context, *wrappers.StringValue) (*empty.Empty, error) {
@@ -124,6 +371,48 @@ func (UnimplementedCacheServiceServer) Delete(context.Context, *wrappers.StringV
 func (UnimplementedCacheServiceServer) IsAlive(context.Context, *empty.Empty) (*wrappers.BoolValue, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method IsAlive not implemented")
 }
+func (UnimplementedCacheServiceServer) CompareAndSet(context.Context, *CompareAndSetRequest) (*wrappers.Int64Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSet not implemented")
+}
+func (UnimplementedCacheServiceServer) SetIfAbsent(context.Context, *StoreKeyValue) (*wrappers.BoolValue, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetIfAbsent not implemented")
+}
+func (UnimplementedCacheServiceServer) Incr(context.Context, *CounterRequest) (*wrappers.Int64Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Incr not implemented")
+}
+func (UnimplementedCacheServiceServer) Decr(context.Context, *CounterRequest) (*wrappers.Int64Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Decr not implemented")
+}
+func (UnimplementedCacheServiceServer) IncrBy(context.Context, *CounterRequest) (*wrappers.Int64Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IncrBy not implemented")
+}
+func (UnimplementedCacheServiceServer) Expire(context.Context, *ExpireRequest) (*wrappers.BoolValue, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Expire not implemented")
+}
+func (UnimplementedCacheServiceServer) TTL(context.Context, *wrappers.StringValue) (*wrappers.Int64Value, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TTL not implemented")
+}
+func (UnimplementedCacheServiceServer) Persist(context.Context, *wrappers.StringValue) (*wrappers.BoolValue, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Persist not implemented")
+}
+func (UnimplementedCacheServiceServer) MGet(context.Context, *Keys) (*KeyResults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MGet not implemented")
+}
+func (UnimplementedCacheServiceServer) MSet(context.Context, *MSetRequest) (*KeyResults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MSet not implemented")
+}
+func (UnimplementedCacheServiceServer) MDelete(context.Context, *Keys) (*KeyResults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MDelete not implemented")
+}
+func (UnimplementedCacheServiceServer) Subscribe(*SubscribeRequest, CacheService_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedCacheServiceServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedCacheServiceServer) LocalStats(context.Context, *empty.Empty) (*LocalTierStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LocalStats not implemented")
+}
 func (UnimplementedCacheServiceServer) mustEmbedUnimplementedCacheServiceServer() {}
 
 // UnsafeCacheServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -209,6 +498,261 @@ func _CacheService_IsAlive_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CacheService_CompareAndSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).CompareAndSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_CompareAndSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).CompareAndSet(ctx, req.(*CompareAndSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_SetIfAbsent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreKeyValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).SetIfAbsent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_SetIfAbsent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).SetIfAbsent(ctx, req.(*StoreKeyValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Incr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Incr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_Incr_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Incr(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Decr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Decr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_Decr_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Decr(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_IncrBy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).IncrBy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_IncrBy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).IncrBy(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Expire_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpireRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Expire(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_Expire_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Expire(ctx, req.(*ExpireRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_TTL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrappers.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).TTL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_TTL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).TTL(ctx, req.(*wrappers.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Persist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrappers.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Persist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_Persist_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Persist(ctx, req.(*wrappers.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_MGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Keys)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).MGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_MGet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).MGet(ctx, req.(*Keys))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_MSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).MSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_MSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).MSet(ctx, req.(*MSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_MDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Keys)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).MDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_MDelete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).MDelete(ctx, req.(*Keys))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CacheServiceServer).Subscribe(m, &cacheServiceSubscribeServer{ServerStream: stream})
+}
+
+type CacheService_SubscribeServer interface {
+	Send(*KeyEvent) error
+	grpc.ServerStream
+}
+
+type cacheServiceSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *cacheServiceSubscribeServer) Send(m *KeyEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CacheService_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_Scan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_LocalStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).LocalStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CacheService_LocalStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).LocalStats(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CacheService_ServiceDesc is the grpc.ServiceDesc for CacheService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -232,7 +776,65 @@ var CacheService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "IsAlive",
 			Handler:    _CacheService_IsAlive_Handler,
 		},
+		{
+			MethodName: "CompareAndSet",
+			Handler:    _CacheService_CompareAndSet_Handler,
+		},
+		{
+			MethodName: "SetIfAbsent",
+			Handler:    _CacheService_SetIfAbsent_Handler,
+		},
+		{
+			MethodName: "Incr",
+			Handler:    _CacheService_Incr_Handler,
+		},
+		{
+			MethodName: "Decr",
+			Handler:    _CacheService_Decr_Handler,
+		},
+		{
+			MethodName: "IncrBy",
+			Handler:    _CacheService_IncrBy_Handler,
+		},
+		{
+			MethodName: "Expire",
+			Handler:    _CacheService_Expire_Handler,
+		},
+		{
+			MethodName: "TTL",
+			Handler:    _CacheService_TTL_Handler,
+		},
+		{
+			MethodName: "Persist",
+			Handler:    _CacheService_Persist_Handler,
+		},
+		{
+			MethodName: "MGet",
+			Handler:    _CacheService_MGet_Handler,
+		},
+		{
+			MethodName: "MSet",
+			Handler:    _CacheService_MSet_Handler,
+		},
+		{
+			MethodName: "MDelete",
+			Handler:    _CacheService_MDelete_Handler,
+		},
+		{
+			MethodName: "Scan",
+			Handler:    _CacheService_Scan_Handler,
+		},
+		{
+			MethodName: "LocalStats",
+			Handler:    _CacheService_LocalStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _CacheService_Subscribe_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "CacheService.proto",
 }
@@ -0,0 +1,7 @@
+package CacheService
+
+// topics CacheService publishes events on
+const (
+	// KeyEvent events of every change of a key
+	KeyspaceTopic = "cache.keyspace"
+)
@@ -0,0 +1,195 @@
+package CacheServiceOwners
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	CacheServiceServant "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/servant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadata of the calls a node forwards to the owner of their key, so the owner doesn't forward them again
+const forwardedMetadata = "cache-forwarded"
+
+// how long the nodes of the service are used before they are discovered again
+const ownersRefreshInterval = 5 * time.Second
+
+// KeyOwners forwards the changes of each key to the node that owns it, see CacheServiceServant.Owner.
+// The owner serializes the changes of its keys, which makes CompareAndSet and the like atomic.
+type KeyOwners struct {
+	self     string
+	discover func() ([]string, error)
+	// called with the keys of the calls forwarded from this node once they return, since the event
+	// of the owner's change may arrive after the caller reads the key again from this node
+	invalidate func(key string)
+
+	mutex       sync.Mutex
+	nodes       []string
+	lastRefresh time.Time
+	// set while a caller discovers the nodes, so the others keep using the nodes discovered before
+	refreshing bool
+	conns      map[string]*grpc.ClientConn
+}
+
+// NewKeyOwners returns the owners of the keys of a service, discovering its nodes with discover.
+// self is the address of this node. The nodes are discovered once before it returns.
+func NewKeyOwners(self string, discover func() ([]string, error), invalidate func(key string)) *KeyOwners {
+	o := &KeyOwners{self: self, discover: discover, invalidate: invalidate, conns: make(map[string]*grpc.ClientConn)}
+	o.refresh()
+	return o
+}
+
+// client returns a client of the owner of key and its address, or false if this node owns it.
+func (o *KeyOwners) client(key string) (CacheServiceClient, string, bool, error) {
+	owner := CacheServiceServant.Owner(key, o.currentNodes())
+	if owner == o.self {
+		return nil, "", false, nil
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	conn, ok := o.conns[owner]
+	if !ok {
+		var err error
+		conn, err = grpc.Dial(owner, grpc.WithInsecure())
+		if err != nil {
+			return nil, owner, true, status.Errorf(codes.Unavailable, "failed to connect to the owner of %s at %v: %v", key, owner, err)
+		}
+		o.conns[owner] = conn
+	}
+	return NewCacheServiceClient(conn), owner, true, nil
+}
+
+// Owns tells whether this node owns key.
+func (o *KeyOwners) Owns(key string) bool {
+	return CacheServiceServant.Owner(key, o.currentNodes()) == o.self
+}
+
+// currentNodes returns the nodes of the service. The caller that finds them stale discovers them
+// again, without holding the mutex, so a slow registry doesn't delay the calls of the other callers.
+func (o *KeyOwners) currentNodes() []string {
+	o.mutex.Lock()
+	stale := time.Since(o.lastRefresh) >= ownersRefreshInterval && !o.refreshing
+	if stale {
+		o.refreshing = true
+	}
+	nodes := o.nodes
+	o.mutex.Unlock()
+	if !stale {
+		return nodes
+	}
+	return o.refresh()
+}
+
+// refresh discovers the nodes of the service, closes the connections to the nodes that left,
+// and returns the nodes. This node is always one of them, also before it registers.
+func (o *KeyOwners) refresh() []string {
+	nodes, err := o.discover()
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.lastRefresh = time.Now()
+	o.refreshing = false
+	if err != nil {
+		log.Printf("Failed to discover the nodes of the service: %v", err)
+		if o.nodes == nil {
+			o.nodes = []string{o.self}
+		}
+		return o.nodes
+	}
+	current := map[string]bool{o.self: true}
+	o.nodes = []string{o.self}
+	for _, node := range nodes {
+		if !current[node] {
+			current[node] = true
+			o.nodes = append(o.nodes, node)
+		}
+	}
+	for node, conn := range o.conns {
+		if !current[node] {
+			conn.Close()
+			delete(o.conns, node)
+		}
+	}
+	return o.nodes
+}
+
+func isForwarded(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(forwardedMetadata)) > 0
+}
+
+// Forward makes a call that changes key on the node owning it, and returns false if this node should
+// handle the call: it owns the key, or the call was forwarded to it. The errors of the owner are returned,
+// Unavailable too: the call may have reached the owner before it failed, so handling it here could apply
+// it twice, or let two nodes change the key at once.
+func Forward[Resp any](ctx context.Context, owners *KeyOwners, key string, call func(ctx context.Context, client CacheServiceClient) (Resp, error)) (Resp, bool, error) {
+	var empty Resp
+	if owners == nil || isForwarded(ctx) {
+		return empty, false, nil
+	}
+	client, _, ok, err := owners.client(key)
+	if !ok {
+		return empty, false, nil
+	}
+	if err != nil {
+		return empty, true, err
+	}
+	resp, err := call(metadata.AppendToOutgoingContext(ctx, forwardedMetadata, owners.self), client)
+	// also on errors: the call may have changed the key before it failed
+	owners.invalidate(key)
+	return resp, true, err
+}
+
+// ForwardBatch forwards the keys of a batch call that other nodes own to their owners, one call per owner,
+// and fills in their results, with the error of the owner's call if it failed, see Forward.
+// It returns the indexes of the keys this node should handle.
+func ForwardBatch(ctx context.Context, owners *KeyOwners, keys []string, results []*KeyResult, call func(ctx context.Context, client CacheServiceClient, indexes []int) ([]*KeyResult, error)) []int {
+	if owners == nil || isForwarded(ctx) {
+		local := make([]int, len(keys))
+		for i := range keys {
+			local[i] = i
+		}
+		return local
+	}
+	var local []int
+	byOwner := make(map[string][]int)
+	clients := make(map[string]CacheServiceClient)
+	var order []string
+	for i, key := range keys {
+		client, owner, ok, err := owners.client(key)
+		if !ok {
+			local = append(local, i)
+			continue
+		}
+		if err != nil {
+			results[i] = &KeyResult{Key: key, Error: err.Error()}
+			continue
+		}
+		if _, ok := byOwner[owner]; !ok {
+			order = append(order, owner)
+			clients[owner] = client
+		}
+		byOwner[owner] = append(byOwner[owner], i)
+	}
+	for _, owner := range order {
+		client := clients[owner]
+		indexes := byOwner[owner]
+		forwarded, err := call(metadata.AppendToOutgoingContext(ctx, forwardedMetadata, owners.self), client, indexes)
+		for j, i := range indexes {
+			owners.invalidate(keys[i])
+			if err != nil {
+				results[i] = &KeyResult{Key: keys[i], Error: err.Error()}
+			} else if j < len(forwarded) {
+				results[i] = forwarded[j]
+			} else {
+				results[i] = &KeyResult{Key: keys[i], Error: "missing result from the owner"}
+			}
+		}
+	}
+	return local
+}
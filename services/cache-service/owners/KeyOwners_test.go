@@ -0,0 +1,266 @@
+package CacheServiceOwners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	CacheServiceServant "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/servant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// the address of the node making the calls, never dialed
+const self = "127.0.0.1:1"
+
+// ownerServer records the calls it handles as the owner of their keys
+type ownerServer struct {
+	UnimplementedCacheServiceServer
+	mutex sync.Mutex
+	// keys of the calls it handled, and whether they were marked as forwarded
+	keys      []string
+	forwarded []bool
+}
+
+func (s *ownerServer) handled(ctx context.Context, keys ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, key := range keys {
+		s.keys = append(s.keys, key)
+		s.forwarded = append(s.forwarded, isForwarded(ctx))
+	}
+}
+
+func (s *ownerServer) calls() ([]string, []bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.keys, s.forwarded
+}
+
+func (s *ownerServer) Set(ctx context.Context, req *StoreKeyValue) (*emptypb.Empty, error) {
+	s.handled(ctx, req.Key)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *ownerServer) MDelete(ctx context.Context, req *Keys) (*KeyResults, error) {
+	s.handled(ctx, req.Keys...)
+	results := make([]*KeyResult, len(req.Keys))
+	for i, key := range req.Keys {
+		results[i] = &KeyResult{Key: key}
+	}
+	return &KeyResults{Results: results}, nil
+}
+
+// startOwner starts an in-process owner node and returns its address.
+func startOwner(t *testing.T) (*ownerServer, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &ownerServer{}
+	grpcServer := grpc.NewServer()
+	RegisterCacheServiceServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	return server, lis.Addr().String()
+}
+
+// newTestOwners returns the owners of the keys of nodes, seen from self, and the keys it invalidated.
+func newTestOwners(t *testing.T, nodes ...string) (*KeyOwners, func() []string) {
+	var mutex sync.Mutex
+	var invalidated []string
+	owners := NewKeyOwners(self, func() ([]string, error) {
+		return nodes, nil
+	}, func(key string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		invalidated = append(invalidated, key)
+	})
+	t.Cleanup(func() {
+		for _, conn := range owners.conns {
+			conn.Close()
+		}
+	})
+	return owners, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return invalidated
+	}
+}
+
+// keyOwnedBy returns a key node owns among nodes.
+func keyOwnedBy(t *testing.T, node string, nodes ...string) string {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if CacheServiceServant.Owner(key, nodes) == node {
+			return key
+		}
+	}
+	t.Fatalf("No key is owned by %v", node)
+	return ""
+}
+
+func set(ctx context.Context, owners *KeyOwners, key string) (bool, error) {
+	_, forwarded, err := Forward(ctx, owners, key, func(ctx context.Context, owner CacheServiceClient) (*emptypb.Empty, error) {
+		return owner.Set(ctx, &StoreKeyValue{Key: key})
+	})
+	return forwarded, err
+}
+
+func TestForwardToOwner(t *testing.T) {
+	server, address := startOwner(t)
+	owners, invalidated := newTestOwners(t, self, address)
+
+	key := keyOwnedBy(t, address, self, address)
+	forwarded, err := set(context.Background(), owners, key)
+	if !forwarded || err != nil {
+		t.Fatalf("Forward(%v) = %v, %v, want the call forwarded to its owner", key, forwarded, err)
+	}
+	if keys, marked := server.calls(); len(keys) != 1 || keys[0] != key || !marked[0] {
+		t.Errorf("owner handled %v (forwarded %v), want %v marked as forwarded", keys, marked, key)
+	}
+	if keys := invalidated(); len(keys) != 1 || keys[0] != key {
+		t.Errorf("invalidated %v, want %v", keys, key)
+	}
+
+	local := keyOwnedBy(t, self, self, address)
+	if forwarded, err := set(context.Background(), owners, local); forwarded || err != nil {
+		t.Errorf("Forward(%v) = %v, %v, want the key this node owns handled locally", local, forwarded, err)
+	}
+	if !owners.Owns(local) || owners.Owns(key) {
+		t.Errorf("Owns(%v) = %v and Owns(%v) = %v, want only %v owned", local, owners.Owns(local), key, owners.Owns(key), local)
+	}
+}
+
+func TestForwardForwarded(t *testing.T) {
+	server, address := startOwner(t)
+	owners, invalidated := newTestOwners(t, self, address)
+
+	key := keyOwnedBy(t, address, self, address)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(forwardedMetadata, "127.0.0.1:2"))
+	if forwarded, err := set(ctx, owners, key); forwarded || err != nil {
+		t.Errorf("Forward(%v) = %v, %v, want a forwarded call handled locally", key, forwarded, err)
+	}
+	if keys, _ := server.calls(); len(keys) != 0 {
+		t.Errorf("owner handled %v, want a forwarded call not forwarded again", keys)
+	}
+	if keys := invalidated(); len(keys) != 0 {
+		t.Errorf("invalidated %v, want nothing", keys)
+	}
+}
+
+func TestForwardUnavailable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	// nothing listens on the owner's address anymore
+	address := lis.Addr().String()
+	lis.Close()
+	owners, invalidated := newTestOwners(t, self, address)
+
+	// the call may have reached the owner, so it isn't handled locally
+	key := keyOwnedBy(t, address, self, address)
+	if forwarded, err := set(context.Background(), owners, key); !forwarded || status.Code(err) != codes.Unavailable {
+		t.Errorf("Forward(%v) = %v, %v, want the Unavailable error of the owner", key, forwarded, err)
+	}
+	if keys := invalidated(); len(keys) != 1 || keys[0] != key {
+		t.Errorf("invalidated %v, want %v", keys, key)
+	}
+
+	keys := []string{key, keyOwnedBy(t, self, self, address)}
+	results := make([]*KeyResult, len(keys))
+	local := ForwardBatch(context.Background(), owners, keys, results, func(ctx context.Context, owner CacheServiceClient, indexes []int) ([]*KeyResult, error) {
+		resp, err := owner.MDelete(ctx, &Keys{Keys: []string{key}})
+		return resp.GetResults(), err
+	})
+	if len(local) != 1 || local[0] != 1 || results[0].GetKey() != key || results[0].GetError() == "" {
+		t.Errorf("ForwardBatch() = %v with results %v, want the error of the owner for %v", local, results, key)
+	}
+}
+
+func TestRefreshDoesNotBlock(t *testing.T) {
+	_, address := startOwner(t)
+	discovering := make(chan struct{})
+	release := make(chan struct{})
+	var calls int
+	owners := NewKeyOwners(self, func() ([]string, error) {
+		calls++
+		if calls > 1 {
+			close(discovering)
+			<-release
+		}
+		return []string{self, address}, nil
+	}, func(string) {})
+	defer close(release)
+
+	key := keyOwnedBy(t, self, self, address)
+	owners.mutex.Lock()
+	owners.lastRefresh = time.Time{}
+	owners.mutex.Unlock()
+	go owners.Owns(key)
+	<-discovering
+
+	// the other callers use the nodes discovered before
+	owned := make(chan bool)
+	go func() { owned <- owners.Owns(key) }()
+	select {
+	case ok := <-owned:
+		if !ok {
+			t.Errorf("Owns(%v) = false while the nodes are discovered", key)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Owns() blocked while another caller discovered the nodes")
+	}
+}
+
+func TestForwardBatchOrder(t *testing.T) {
+	first, firstAddress := startOwner(t)
+	second, secondAddress := startOwner(t)
+	nodes := []string{self, firstAddress, secondAddress}
+	owners, invalidated := newTestOwners(t, nodes...)
+
+	var keys []string
+	for i := 0; i < 30; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+	}
+	results := make([]*KeyResult, len(keys))
+	local := ForwardBatch(context.Background(), owners, keys, results, func(ctx context.Context, owner CacheServiceClient, indexes []int) ([]*KeyResult, error) {
+		forwarded := &Keys{}
+		for _, i := range indexes {
+			forwarded.Keys = append(forwarded.Keys, keys[i])
+		}
+		resp, err := owner.MDelete(ctx, forwarded)
+		return resp.GetResults(), err
+	})
+
+	var want []int
+	for i, key := range keys {
+		if CacheServiceServant.Owner(key, nodes) == self {
+			want = append(want, i)
+			if results[i] != nil {
+				t.Errorf("results[%v] = %v, want no result for a key this node owns", i, results[i])
+			}
+		} else if results[i].GetKey() != key || results[i].Error != "" {
+			t.Errorf("results[%v] = %v, want the owner's result of %v", i, results[i], key)
+		}
+	}
+	if fmt.Sprint(local) != fmt.Sprint(want) {
+		t.Errorf("ForwardBatch() = %v, want the indexes of the keys this node owns %v", local, want)
+	}
+	firstKeys, _ := first.calls()
+	secondKeys, _ := second.calls()
+	if len(firstKeys) == 0 || len(secondKeys) == 0 || len(firstKeys)+len(secondKeys)+len(local) != len(keys) {
+		t.Errorf("owners handled %v and %v, want each key handled once", firstKeys, secondKeys)
+	}
+	if len(invalidated()) != len(keys)-len(local) {
+		t.Errorf("invalidated %v, want the %v forwarded keys", invalidated(), len(keys)-len(local))
+	}
+}
@@ -0,0 +1,48 @@
+package CacheServiceServant
+
+import (
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+func newKeyResult(key string, err error) *KeyResult {
+	result := &KeyResult{Key: key}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// MGet reads many keys. The keys that fail have their error in their result.
+func (c *Cache) MGet(keys []string) []*KeyResult {
+	results := make([]*KeyResult, len(keys))
+	for i, key := range keys {
+		entry, err := c.GetEntry(key)
+		results[i] = newKeyResult(key, err)
+		if entry != nil {
+			results[i].Value = entry.Value
+			results[i].Version = entry.Version
+			results[i].Found = true
+		}
+	}
+	return results
+}
+
+// MSet stores many values, each with its own time to live.
+func (c *Cache) MSet(entries []*StoreKeyValue) []*KeyResult {
+	results := make([]*KeyResult, len(entries))
+	for i, entry := range entries {
+		err := c.Set(entry.Key, entry.Value, time.Duration(entry.TtlMs)*time.Millisecond)
+		results[i] = newKeyResult(entry.Key, err)
+	}
+	return results
+}
+
+func (c *Cache) MDelete(keys []string) []*KeyResult {
+	results := make([]*KeyResult, len(keys))
+	for i, key := range keys {
+		results[i] = newKeyResult(key, c.Delete(key))
+	}
+	return results
+}
@@ -0,0 +1,99 @@
+package CacheServiceServant
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+func TestCacheBatch(t *testing.T) {
+	cache, _, _ := newTestCache()
+
+	results := cache.MSet([]*StoreKeyValue{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2"), TtlMs: 1000},
+		{Key: "c", Value: []byte("3"), TtlMs: -1},
+	})
+	if results[0].Error != "" || results[1].Error != "" {
+		t.Errorf("MSet() failed: %v", results)
+	}
+	if results[2].Error != ErrInvalidTTL.Error() {
+		t.Errorf("MSet() of a negative ttl = %v, want %v", results[2], ErrInvalidTTL)
+	}
+
+	results = cache.MGet([]string{"a", "b", "c"})
+	if !results[0].Found || string(results[0].Value) != "1" || !results[1].Found || string(results[1].Value) != "2" {
+		t.Errorf("MGet() = %v, want a and b", results)
+	}
+	if results[2].Found || results[2].Key != "c" {
+		t.Errorf("MGet() found c: %v", results[2])
+	}
+
+	cache.MDelete([]string{"a", "b"})
+	for _, result := range cache.MGet([]string{"a", "b"}) {
+		if result.Found {
+			t.Errorf("MGet() found deleted key %v", result.Key)
+		}
+	}
+}
+
+// failingRing is a mapRing failing the reads of one key
+type failingRing struct {
+	*mapRing
+	failing string
+}
+
+func (r *failingRing) Get(key string) (string, error) {
+	if key == r.failing {
+		return "", errors.New("ring unavailable")
+	}
+	return r.mapRing.Get(key)
+}
+
+func TestCacheBatchPerKey(t *testing.T) {
+	ring := &failingRing{mapRing: newMapRing(), failing: "broken"}
+	cache := NewCache(ring)
+	cache.MaxValueBytes = 4
+	reserved := ChunkKeyPrefix + "a"
+
+	results := cache.MSet([]*StoreKeyValue{
+		{Key: "a", Value: []byte("1")},
+		{Key: "large", Value: []byte("12345")},
+		{Key: reserved, Value: []byte("2")},
+		{Key: "b", Value: []byte("3")},
+	})
+	want := []string{"", ErrValueTooLarge.Error(), ErrReservedKey.Error(), ""}
+	for i, result := range results {
+		if result.Key != []string{"a", "large", reserved, "b"}[i] || result.Error != want[i] {
+			t.Errorf("MSet() result %v = %v, want error %q", i, result, want[i])
+		}
+	}
+	cache.Set("a", []byte("4"), 0)
+
+	// hits, misses and failures are told apart per key, in the order of the keys
+	results = cache.MGet([]string{"b", "missing", "a", "broken", "large"})
+	if b := results[0]; b.Key != "b" || !b.Found || string(b.Value) != "3" || b.Version == 0 || b.Error != "" {
+		t.Errorf("MGet() result for b = %v", b)
+	}
+	if missing := results[1]; missing.Key != "missing" || missing.Found || missing.Error != "" {
+		t.Errorf("MGet() result for a missing key = %v", missing)
+	}
+	if a := results[2]; a.Key != "a" || string(a.Value) != "4" || a.Version <= results[0].Version {
+		t.Errorf("MGet() result for a = %v, want the version of its second set", a)
+	}
+	if broken := results[3]; broken.Key != "broken" || broken.Found || broken.Error == "" {
+		t.Errorf("MGet() result for a key the ring fails to read = %v", broken)
+	}
+	if large := results[4]; large.Found || large.Error != "" {
+		t.Errorf("MGet() result for a key that wasn't set = %v", large)
+	}
+
+	results = cache.MDelete([]string{"a", "missing", reserved})
+	if results[0].Error != "" || results[1].Error != "" || results[2].Error != ErrReservedKey.Error() {
+		t.Errorf("MDelete() = %v, want only the reserved key to fail", results)
+	}
+	if results := cache.MGet([]string{"a", "b"}); results[0].Found || !results[1].Found {
+		t.Errorf("MGet() after MDelete(a) = %v", results)
+	}
+}
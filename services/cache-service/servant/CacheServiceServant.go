@@ -0,0 +1,540 @@
+package CacheServiceServant
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// TTL results for keys without a time to live, as in the TTL RPC
+const (
+	TTLMissing   = -2 * time.Millisecond
+	TTLPersisted = -1 * time.Millisecond
+)
+
+// ChunkKeyPrefix starts the keys chunks of large values are stored under. Keys with it are reserved.
+const ChunkKeyPrefix = "__cache_chunk__/"
+
+// how many times an entry is loaded when its chunks are deleted while they are read
+const assembleAttempts = 3
+
+var (
+	// ErrInvalidTTL is returned when a time to live isn't positive.
+	ErrInvalidTTL = errors.New("ttl must be positive")
+	// ErrVersionMismatch is returned by CompareAndSet when the key has another version.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrValueTooLarge is returned when a value is larger than Cache.MaxValueBytes.
+	ErrValueTooLarge = errors.New("value is too large")
+	// ErrReservedKey is returned for keys starting with ChunkKeyPrefix.
+	ErrReservedKey = errors.New("key is reserved")
+
+	// errChunksMissing is returned by assemble when chunks of an entry are missing or short,
+	// as when the owner of the key replaced them while they were read.
+	errChunksMissing = errors.New("chunks are missing")
+)
+
+// Ring is the distributed map the cache stores its entries in. *dht.Chord implements it.
+type Ring interface {
+	Set(key string, value string) error
+	Get(key string) (string, error)
+	Delete(key string) error
+	GetAllKeys() ([]string, error)
+}
+
+// Cache stores the entries of the cache service in the ring. Every value is stored as the
+// protojson encoding of a CacheEntry, which carries its expiry time and version.
+// A Cache serializes the changes it makes, so the changes of a key must all go
+// through the same node to be atomic, see Owner.
+type Cache struct {
+	// Local keeps recently read entries in memory if set. Set it before the first call
+	Local *LocalTier
+	// OnEvent is called with the event of every change this node makes, for the subscribers of the key
+	// and to invalidate the local tiers of the other nodes. It must not call the cache
+	OnEvent func(event *KeyEvent)
+	// Log persists the changes this node makes if set, see Restore. Set it before the first call
+	Log *WAL
+	// largest value the cache stores, 0 for no limit
+	MaxValueBytes int
+	// values larger than ChunkBytes are split into chunks of ChunkBytes, 0 to never split them
+	ChunkBytes int
+
+	mutex sync.Mutex
+	ring  Ring
+	now   func() time.Time
+
+	// the keys left to scan after the cursors Scan returned, see scanSnapshot
+	scanMutex sync.Mutex
+	scans     map[string]*scanSnapshot
+}
+
+func NewCache(ring Ring) *Cache {
+	return &Cache{ring: ring, now: time.Now}
+}
+
+func chunkKey(key string, chunkSet int64, i int) string {
+	return fmt.Sprintf("%s%s/%d/%d", ChunkKeyPrefix, key, chunkSet, i)
+}
+
+func checkKey(key string) error {
+	if strings.HasPrefix(key, ChunkKeyPrefix) {
+		return ErrReservedKey
+	}
+	return nil
+}
+
+// checkWrite checks the arguments of a call storing a value.
+func (c *Cache) checkWrite(key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		return ErrInvalidTTL
+	}
+	if c.MaxValueBytes > 0 && len(value) > c.MaxValueBytes {
+		return ErrValueTooLarge
+	}
+	return checkKey(key)
+}
+
+// load returns the entry stored under a key, without the value of a chunked entry.
+func (c *Cache) load(key string) (*CacheEntry, error) {
+	serialized, err := c.ring.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	// the ring returns an empty string for missing keys
+	if serialized == "" {
+		return nil, nil
+	}
+	entry := &CacheEntry{}
+	if err := protojson.Unmarshal([]byte(serialized), entry); err != nil {
+		return nil, fmt.Errorf("failed to parse entry of %s: %v", key, err)
+	}
+	return entry, nil
+}
+
+// loadLive returns the entry of a key, or nil if it is missing or expired.
+// Expired entries are left for the sweeper to delete.
+func (c *Cache) loadLive(key string) (*CacheEntry, error) {
+	entry, err := c.load(key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if c.expired(entry) {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// loadVersion returns the live entry of a key, and its stored entry, expired or not,
+// to version the next write after.
+func (c *Cache) loadVersion(key string) (live *CacheEntry, stored *CacheEntry, err error) {
+	stored, err = c.load(key)
+	if err != nil || stored == nil {
+		return nil, nil, err
+	}
+	if c.expired(stored) {
+		return nil, stored, nil
+	}
+	return stored, stored, nil
+}
+
+// assemble returns an entry with the value of its chunks.
+func (c *Cache) assemble(key string, entry *CacheEntry) (*CacheEntry, error) {
+	if entry.Chunks == 0 {
+		return entry, nil
+	}
+	value := make([]byte, 0, entry.Size)
+	for i := 0; i < int(entry.Chunks); i++ {
+		encoded, err := c.ring.Get(chunkKey(key, entry.ChunkSet, i))
+		if err != nil {
+			return nil, err
+		}
+		if encoded == "" {
+			return nil, fmt.Errorf("chunk %v of %s: %w", i, key, errChunksMissing)
+		}
+		chunk, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode chunk %v of %s: %v", i, key, err)
+		}
+		value = append(value, chunk...)
+	}
+	if int64(len(value)) != entry.Size {
+		return nil, fmt.Errorf("chunks of %s: %w", key, errChunksMissing)
+	}
+	return &CacheEntry{Value: value, ExpiresAt: entry.ExpiresAt, Version: entry.Version}, nil
+}
+
+// loadAssembled returns the live entry of a key with the value of its chunks, or nil if it is missing
+// or expired. The owner of the key may write it again on another node while its chunks are read,
+// and delete them, so the entry is loaded again when they are missing.
+func (c *Cache) loadAssembled(key string) (*CacheEntry, error) {
+	for attempt := 1; ; attempt++ {
+		entry, err := c.loadLive(key)
+		if err != nil || entry == nil {
+			return nil, err
+		}
+		assembled, err := c.assemble(key, entry)
+		if !errors.Is(err, errChunksMissing) || attempt == assembleAttempts {
+			return assembled, err
+		}
+	}
+}
+
+// nextVersion returns the version of a write after the stored entry. Versions are the time of the write
+// in microseconds, so they keep growing when a key is deleted and set again.
+func (c *Cache) nextVersion(stored *CacheEntry) int64 {
+	version := c.now().UnixMicro()
+	if stored != nil && version <= stored.Version {
+		version = stored.Version + 1
+	}
+	return version
+}
+
+// store versions an entry after the stored one, logs it, and writes it to the ring and through to the local tier.
+// Entries must not be changed after they are stored.
+func (c *Cache) store(key string, entry *CacheEntry, stored *CacheEntry, eventType KeyEventType) error {
+	entry.Version = c.nextVersion(stored)
+	event := &KeyEvent{Type: eventType, Key: key, Version: entry.Version}
+	if err := c.logChange(event, entry); err != nil {
+		return err
+	}
+	head, err := c.write(key, entry, stored)
+	if err != nil {
+		return err
+	}
+	if eventType == KeyEventType_KEY_SET {
+		if head == entry {
+			event.Value = entry.Value
+		} else {
+			event.Chunked = true
+		}
+	}
+	c.written(key, entry, event)
+	return nil
+}
+
+// logChange appends a change to the write-ahead log before it is written to the ring, and fails if
+// the log can't persist it, in which case the change isn't made. See WAL.Append for when it fails.
+// A change whose write to the ring fails is still in the log, and may be restored.
+func (c *Cache) logChange(event *KeyEvent, entry *CacheEntry) error {
+	if record := walRecord(event, entry); record != nil && c.Log != nil {
+		if err := c.Log.Append(record); err != nil {
+			return fmt.Errorf("failed to persist the change of %s: %v", event.Key, err)
+		}
+	}
+	return nil
+}
+
+// write writes a versioned entry to the ring, and returns the entry stored under its key.
+// A value larger than ChunkBytes is written in chunks first, so readers never see a part of it.
+// The chunks of the stored entry are deleted once they aren't used anymore.
+func (c *Cache) write(key string, entry *CacheEntry, stored *CacheEntry) (*CacheEntry, error) {
+	head := entry
+	if c.ChunkBytes > 0 && len(entry.Value) > c.ChunkBytes {
+		head = &CacheEntry{ExpiresAt: entry.ExpiresAt, Version: entry.Version, ChunkSet: entry.Version, Size: int64(len(entry.Value))}
+		for start := 0; start < len(entry.Value); start += c.ChunkBytes {
+			chunk := entry.Value[start:min(start+c.ChunkBytes, len(entry.Value))]
+			err := c.ring.Set(chunkKey(key, head.ChunkSet, int(head.Chunks)), base64.StdEncoding.EncodeToString(chunk))
+			if err != nil {
+				head.Chunks++
+				c.deleteChunks(key, head)
+				return nil, err
+			}
+			head.Chunks++
+		}
+	}
+	serialized, err := protojson.Marshal(head)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ring.Set(key, string(serialized)); err != nil {
+		c.written(key, nil, nil)
+		return nil, err
+	}
+	if stored != nil && stored.Chunks > 0 && stored.ChunkSet != head.ChunkSet {
+		c.deleteChunks(key, stored)
+	}
+	return head, nil
+}
+
+func (c *Cache) deleteChunks(key string, entry *CacheEntry) {
+	for i := 0; i < int(entry.Chunks); i++ {
+		if err := c.ring.Delete(chunkKey(key, entry.ChunkSet, i)); err != nil {
+			log.Printf("Failed to delete chunk %v of %s: %v\n", i, key, err)
+		}
+	}
+}
+
+// delete deletes a key with its chunks. eventType tells whether it was deleted or expired.
+func (c *Cache) delete(key string, eventType KeyEventType) error {
+	event := &KeyEvent{Type: eventType, Key: key}
+	if err := c.logChange(event, nil); err != nil {
+		return err
+	}
+	stored, err := c.load(key)
+	if err != nil {
+		log.Printf("Failed to read %s before deleting it, its chunks are kept: %v\n", key, err)
+	}
+	err = c.ring.Delete(key)
+	if err != nil {
+		c.written(key, nil, nil)
+		return err
+	}
+	c.written(key, nil, event)
+	if stored != nil && stored.Chunks > 0 {
+		c.deleteChunks(key, stored)
+	}
+	return nil
+}
+
+// written updates the local tier after a write to the ring, and sends its event.
+// entry is nil if the key was deleted, or if the write failed and its state is unknown,
+// in which case there is no event. Restored entries have no event either.
+func (c *Cache) written(key string, entry *CacheEntry, event *KeyEvent) {
+	if c.Local != nil {
+		// entries that refer to chunks don't have their value
+		if entry != nil && entry.Chunks == 0 {
+			c.Local.Put(key, entry)
+		} else {
+			c.Local.Invalidate(key)
+		}
+	}
+	if event != nil && c.OnEvent != nil {
+		c.OnEvent(event)
+	}
+}
+
+// Invalidate drops a key another node changed from the local tier.
+func (c *Cache) Invalidate(key string) {
+	if c.Local != nil {
+		c.Local.Invalidate(key)
+	}
+}
+
+func (c *Cache) expired(entry *CacheEntry) bool {
+	return entry.ExpiresAt != 0 && c.now().UnixMilli() >= entry.ExpiresAt
+}
+
+func (c *Cache) expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return c.now().Add(ttl).UnixMilli()
+}
+
+// Set stores a value. A ttl of 0 keeps it until it is deleted.
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+	if err := c.checkWrite(key, value, ttl); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, stored, err := c.loadVersion(key)
+	if err != nil {
+		return err
+	}
+	return c.store(key, &CacheEntry{Value: value, ExpiresAt: c.expiresAt(ttl)}, stored, KeyEventType_KEY_SET)
+}
+
+// CompareAndSet stores a value if the key has the expected version, or is missing and expected is 0,
+// and returns the new version. It returns ErrVersionMismatch if the key has another version.
+func (c *Cache) CompareAndSet(key string, expected int64, value []byte, ttl time.Duration) (int64, error) {
+	if err := c.checkWrite(key, value, ttl); err != nil {
+		return 0, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	live, stored, err := c.loadVersion(key)
+	if err != nil {
+		return 0, err
+	}
+	current := int64(0)
+	if live != nil {
+		current = live.Version
+	}
+	if current != expected {
+		return 0, ErrVersionMismatch
+	}
+	entry := &CacheEntry{Value: value, ExpiresAt: c.expiresAt(ttl)}
+	if err := c.store(key, entry, stored, KeyEventType_KEY_SET); err != nil {
+		return 0, err
+	}
+	return entry.Version, nil
+}
+
+// SetIfAbsent stores a value if the key is missing or expired, and returns false if it exists.
+func (c *Cache) SetIfAbsent(key string, value []byte, ttl time.Duration) (bool, error) {
+	if err := c.checkWrite(key, value, ttl); err != nil {
+		return false, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	live, stored, err := c.loadVersion(key)
+	if err != nil || live != nil {
+		return false, err
+	}
+	return true, c.store(key, &CacheEntry{Value: value, ExpiresAt: c.expiresAt(ttl)}, stored, KeyEventType_KEY_SET)
+}
+
+// Get returns the value of a key, and false if it is missing or expired.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	entry, err := c.GetEntry(key)
+	if err != nil || entry == nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+// GetEntry returns the entry of a key with its whole value, or nil if it is missing or expired.
+// The entry must not be changed. Keys in the local tier are read from it, without going to the ring.
+func (c *Cache) GetEntry(key string) (*CacheEntry, error) {
+	if checkKey(key) != nil {
+		return nil, nil
+	}
+	if c.Local != nil {
+		if entry, ok := c.Local.Get(key); ok {
+			if c.expired(entry) {
+				return nil, nil
+			}
+			return entry, nil
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var generation uint64
+	if c.Local != nil {
+		generation = c.Local.Generation()
+	}
+	entry, err := c.loadAssembled(key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if c.Local != nil {
+		c.Local.Fill(key, entry, generation)
+	}
+	return entry, nil
+}
+
+func (c *Cache) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.delete(key, KeyEventType_KEY_DELETE)
+}
+
+// Expire sets the time to live of a key, and returns false if it is missing or expired.
+func (c *Cache) Expire(key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, ErrInvalidTTL
+	}
+	if checkKey(key) != nil {
+		return false, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, err := c.loadLive(key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return true, c.store(key, withExpiry(entry, c.expiresAt(ttl)), entry, KeyEventType_KEY_EXPIRE)
+}
+
+// withExpiry returns a copy of an entry, with its chunks, that expires at expiresAt.
+func withExpiry(entry *CacheEntry, expiresAt int64) *CacheEntry {
+	return &CacheEntry{Value: entry.Value, ExpiresAt: expiresAt, Chunks: entry.Chunks, ChunkSet: entry.ChunkSet, Size: entry.Size}
+}
+
+// TTL returns the remaining time to live of a key, TTLPersisted if it doesn't expire
+// and TTLMissing if it is missing or expired.
+func (c *Cache) TTL(key string) (time.Duration, error) {
+	if checkKey(key) != nil {
+		return TTLMissing, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, err := c.loadLive(key)
+	if err != nil {
+		return 0, err
+	}
+	if entry == nil {
+		return TTLMissing, nil
+	}
+	if entry.ExpiresAt == 0 {
+		return TTLPersisted, nil
+	}
+	return time.UnixMilli(entry.ExpiresAt).Sub(c.now()), nil
+}
+
+// Persist removes the time to live of a key, and returns false if it is missing or didn't expire.
+func (c *Cache) Persist(key string) (bool, error) {
+	if checkKey(key) != nil {
+		return false, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, err := c.loadLive(key)
+	if err != nil || entry == nil || entry.ExpiresAt == 0 {
+		return false, err
+	}
+	return true, c.store(key, withExpiry(entry, 0), entry, KeyEventType_KEY_EXPIRE)
+}
+
+// Sweep deletes the expired entries of the keys this node owns, with their chunks, and returns how many
+// it deleted. owns tells whether this node owns a key, nil to sweep every key. Only the owner of a key
+// may sweep it: the owner serializes the writes of the key, see Owner, so a key set again between
+// the sweeper reading it and deleting it is never deleted.
+func (c *Cache) Sweep(owns func(key string) bool) (int, error) {
+	keys, err := c.ring.GetAllKeys()
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, key := range keys {
+		if checkKey(key) != nil || (owns != nil && !owns(key)) {
+			continue
+		}
+		// entries are read again under the mutex, in case they were set since
+		c.mutex.Lock()
+		entry, err := c.load(key)
+		if err == nil && entry != nil && c.expired(entry) {
+			err = c.delete(key, KeyEventType_KEY_EXPIRED)
+			if err == nil {
+				deleted++
+			}
+		}
+		c.mutex.Unlock()
+		if err != nil {
+			log.Printf("Failed to sweep %s: %v\n", key, err)
+		}
+	}
+	return deleted, nil
+}
+
+// RunSweeper sweeps the keys this node owns every interval until stop is closed, see Sweep.
+func (c *Cache) RunSweeper(interval time.Duration, owns func(key string) bool, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		deleted, err := c.Sweep(owns)
+		if err != nil {
+			log.Printf("Failed to sweep expired entries: %v\n", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Swept %v expired entries\n", deleted)
+		}
+	}
+}
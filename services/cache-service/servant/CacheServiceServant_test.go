@@ -0,0 +1,328 @@
+package CacheServiceServant
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapRing is an in-memory Ring
+type mapRing struct {
+	mutex sync.Mutex
+	data  map[string]string
+}
+
+func newMapRing() *mapRing {
+	return &mapRing{data: make(map[string]string)}
+}
+
+func (r *mapRing) Set(key string, value string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.data[key] = value
+	return nil
+}
+
+func (r *mapRing) Get(key string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.data[key], nil
+}
+
+func (r *mapRing) Delete(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.data, key)
+	return nil
+}
+
+func (r *mapRing) GetAllKeys() ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	keys := make([]string, 0, len(r.data))
+	for key := range r.data {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// newTestCache returns a cache over a mapRing with a clock the test moves with advance
+func newTestCache() (*Cache, *mapRing, func(time.Duration)) {
+	ring := newMapRing()
+	cache := NewCache(ring)
+	now := time.UnixMilli(1_000_000)
+	cache.now = func() time.Time { return now }
+	return cache, ring, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache, _, advance := newTestCache()
+
+	if err := cache.Set("session", []byte("alice"), time.Second); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := cache.Set("page", []byte("<html>"), 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if ttl, _ := cache.TTL("session"); ttl != time.Second {
+		t.Errorf("TTL(session) = %v, want 1s", ttl)
+	}
+	if ttl, _ := cache.TTL("page"); ttl != TTLPersisted {
+		t.Errorf("TTL(page) = %v, want %v", ttl, TTLPersisted)
+	}
+	if ttl, _ := cache.TTL("missing"); ttl != TTLMissing {
+		t.Errorf("TTL(missing) = %v, want %v", ttl, TTLMissing)
+	}
+
+	advance(999 * time.Millisecond)
+	if value, ok, _ := cache.Get("session"); !ok || string(value) != "alice" {
+		t.Errorf("Get(session) = %q, %v before it expired", value, ok)
+	}
+	advance(time.Millisecond)
+	if value, ok, _ := cache.Get("session"); ok {
+		t.Errorf("Get(session) = %q after it expired", value)
+	}
+	if ok, _ := cache.Expire("session", time.Second); ok {
+		t.Errorf("Expire() of an expired key succeeded")
+	}
+
+	if ok, _ := cache.Expire("page", time.Minute); !ok {
+		t.Errorf("Expire(page) failed")
+	}
+	if ttl, _ := cache.TTL("page"); ttl != time.Minute {
+		t.Errorf("TTL(page) = %v, want 1m", ttl)
+	}
+	if ok, _ := cache.Persist("page"); !ok {
+		t.Errorf("Persist(page) failed")
+	}
+	if ok, _ := cache.Persist("page"); ok {
+		t.Errorf("Persist() of a persisted key succeeded")
+	}
+	advance(time.Hour)
+	if _, ok, _ := cache.Get("page"); !ok {
+		t.Errorf("Get(page) missed a persisted key")
+	}
+
+	if _, err := cache.Expire("page", 0); err != ErrInvalidTTL {
+		t.Errorf("Expire(page, 0) = %v, want %v", err, ErrInvalidTTL)
+	}
+	if err := cache.Set("page", []byte("<html>"), -time.Second); err != ErrInvalidTTL {
+		t.Errorf("Set() with a negative ttl = %v, want %v", err, ErrInvalidTTL)
+	}
+}
+
+func TestCacheSweep(t *testing.T) {
+	cache, ring, advance := newTestCache()
+
+	cache.Set("a", []byte("1"), time.Second)
+	cache.Set("b", []byte("2"), time.Minute)
+	cache.Set("c", []byte("3"), 0)
+	advance(time.Second)
+
+	deleted, err := cache.Sweep(nil)
+	if err != nil {
+		t.Fatalf("Sweep() failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Sweep() deleted %v entries, want 1", deleted)
+	}
+	keys, _ := ring.GetAllKeys()
+	if len(keys) != 2 || ring.data["a"] != "" {
+		t.Errorf("ring keys after sweep = %v, want b and c", keys)
+	}
+}
+
+// hookRing is a mapRing calling onGet before it reads a key
+type hookRing struct {
+	*mapRing
+	onGet func(key string)
+}
+
+func (r *hookRing) Get(key string) (string, error) {
+	r.onGet(key)
+	return r.mapRing.Get(key)
+}
+
+func TestCacheChunksReplacedWhileRead(t *testing.T) {
+	var once sync.Once
+	ring := &hookRing{mapRing: newMapRing(), onGet: func(string) {}}
+	cache := NewCache(ring)
+	cache.ChunkBytes = 4
+	// the owner of the key, on another node
+	owner := NewCache(ring.mapRing)
+	owner.ChunkBytes = 4
+	owner.Set("image", []byte("0123456789"), 0)
+
+	ring.onGet = func(key string) {
+		if strings.HasPrefix(key, ChunkKeyPrefix) {
+			once.Do(func() {
+				owner.Set("image", []byte("abcdefghij"), 0)
+			})
+		}
+	}
+	if value, ok, err := cache.Get("image"); err != nil || !ok || string(value) != "abcdefghij" {
+		t.Errorf("Get(image) = %q, %v, %v, want the value that replaced the chunks read", value, ok, err)
+	}
+
+	// chunks missing for good fail the read
+	ring.onGet = func(string) {}
+	for key := range ring.data {
+		if strings.HasPrefix(key, ChunkKeyPrefix) && strings.HasSuffix(key, "/1") {
+			delete(ring.data, key)
+		}
+	}
+	if _, _, err := cache.Get("image"); err == nil {
+		t.Errorf("Get(image) succeeded with a chunk missing")
+	}
+}
+
+func TestCacheSweepConcurrentSet(t *testing.T) {
+	var once sync.Once
+	set := make(chan error, 1)
+	var cache *Cache
+	ring := &hookRing{mapRing: newMapRing(), onGet: func(string) {}}
+	cache = NewCache(ring)
+	now := time.UnixMilli(1_000_000)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", []byte("old"), time.Second)
+	cache.Set("b", []byte("other"), time.Second)
+	now = now.Add(time.Second)
+
+	if deleted, _ := cache.Sweep(func(key string) bool { return key != "b" }); deleted != 1 {
+		t.Errorf("Sweep() deleted %v entries, want only a, the key this node owns", deleted)
+	}
+	cache.Set("a", []byte("old"), time.Second)
+	now = now.Add(time.Second)
+	ring.onGet = func(key string) {
+		once.Do(func() {
+			// the owner sets the key again while the sweeper reads its expired entry
+			go func() { set <- cache.Set(key, []byte("new"), 0) }()
+			time.Sleep(50 * time.Millisecond)
+		})
+	}
+	if _, err := cache.Sweep(func(key string) bool { return key == "a" }); err != nil {
+		t.Fatalf("Sweep() failed: %v", err)
+	}
+	if err := <-set; err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if value, ok, _ := cache.Get("a"); !ok || string(value) != "new" {
+		t.Errorf("Get(a) = %q, %v, want the value set while it was swept", value, ok)
+	}
+}
+
+func TestCacheCompareAndSet(t *testing.T) {
+	cache, _, advance := newTestCache()
+
+	version, err := cache.CompareAndSet("counter", 0, []byte("1"), 0)
+	if err != nil {
+		t.Fatalf("CompareAndSet() of a missing key failed: %v", err)
+	}
+	if _, err := cache.CompareAndSet("counter", 0, []byte("1"), 0); err != ErrVersionMismatch {
+		t.Errorf("CompareAndSet() of an existing key = %v, want %v", err, ErrVersionMismatch)
+	}
+	// versions grow even when the clock doesn't move
+	next, err := cache.CompareAndSet("counter", version, []byte("2"), 0)
+	if err != nil || next <= version {
+		t.Fatalf("CompareAndSet() = %v, %v, want a version after %v", next, err, version)
+	}
+	if _, err := cache.CompareAndSet("counter", version, []byte("3"), 0); err != ErrVersionMismatch {
+		t.Errorf("CompareAndSet() of a stale version = %v, want %v", err, ErrVersionMismatch)
+	}
+	if entry, _ := cache.GetEntry("counter"); string(entry.Value) != "2" || entry.Version != next {
+		t.Errorf("GetEntry() = %v, want value 2 at version %v", entry, next)
+	}
+
+	// a key set again after it is deleted doesn't reuse a version
+	cache.Delete("counter")
+	advance(-time.Second)
+	again, _ := cache.CompareAndSet("counter", 0, []byte("1"), 0)
+	if again == version {
+		t.Errorf("a deleted key was set again at its first version")
+	}
+
+	if ok, _ := cache.SetIfAbsent("lock", []byte("alice"), time.Second); !ok {
+		t.Errorf("SetIfAbsent() of a missing key failed")
+	}
+	if ok, _ := cache.SetIfAbsent("lock", []byte("bob"), time.Second); ok {
+		t.Errorf("SetIfAbsent() of an existing key succeeded")
+	}
+	advance(time.Second)
+	if ok, _ := cache.SetIfAbsent("lock", []byte("bob"), time.Second); !ok {
+		t.Errorf("SetIfAbsent() of an expired key failed")
+	}
+	if value, _, _ := cache.Get("lock"); string(value) != "bob" {
+		t.Errorf("Get(lock) = %q, want bob", value)
+	}
+}
+
+func TestOwner(t *testing.T) {
+	nodes := []string{"127.0.0.1:1000", "127.0.0.1:1001", "127.0.0.1:1002"}
+	owners := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key] = Owner(key, nodes)
+	}
+	// nodes on one host own their share of the keys
+	counts := make(map[string]int)
+	for _, owner := range owners {
+		counts[owner]++
+	}
+	for _, node := range nodes {
+		if counts[node] < 20 {
+			t.Errorf("%v owns %v of 100 keys, want about a third", node, counts[node])
+		}
+	}
+	// only the keys of the node that left move
+	for key, owner := range owners {
+		moved := Owner(key, nodes[:2])
+		if owner != nodes[2] && moved != owner {
+			t.Errorf("%v moved from %v to %v", key, owner, moved)
+		}
+	}
+	if owner := Owner("key", nil); owner != "" {
+		t.Errorf("Owner() without nodes = %v", owner)
+	}
+}
+
+func TestCacheChunks(t *testing.T) {
+	cache, ring, _ := newTestCache()
+	cache.ChunkBytes = 4
+	cache.MaxValueBytes = 16
+
+	image := []byte{0xff, 0xd8, 0xff, 0xe0, 0, 1, 2, 3, 4, 5}
+	if err := cache.Set("image", image, 0); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	// the entry and 3 chunks
+	if len(ring.data) != 4 {
+		t.Errorf("ring has %v keys, want 4", len(ring.data))
+	}
+	if value, ok, err := cache.Get("image"); err != nil || !ok || string(value) != string(image) {
+		t.Errorf("Get(image) = %v, %v, %v, want %v", value, ok, err, image)
+	}
+
+	// chunks are kept by Expire, and replaced by Set
+	cache.Expire("image", time.Hour)
+	if value, _, _ := cache.Get("image"); string(value) != string(image) {
+		t.Errorf("Get(image) = %v after Expire, want %v", value, image)
+	}
+	cache.Set("image", []byte("small"), 0)
+	if len(ring.data) != 3 {
+		t.Errorf("ring has %v keys after a replacing set, want 3", len(ring.data))
+	}
+	cache.Set("image", image, 0)
+	cache.Delete("image")
+	if len(ring.data) != 0 {
+		t.Errorf("ring has %v keys after delete, want 0", len(ring.data))
+	}
+
+	if err := cache.Set("image", make([]byte, 17), 0); err != ErrValueTooLarge {
+		t.Errorf("Set() of a large value = %v, want %v", err, ErrValueTooLarge)
+	}
+	if err := cache.Set(ChunkKeyPrefix+"image", image, 0); err != ErrReservedKey {
+		t.Errorf("Set() of a reserved key = %v, want %v", err, ErrReservedKey)
+	}
+}
@@ -0,0 +1,50 @@
+package CacheServiceServant
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+var (
+	// ErrNotInteger is returned by IncrBy when the value of the key isn't an integer.
+	ErrNotInteger = errors.New("value is not an integer")
+	// ErrOverflow is returned by IncrBy when the new value doesn't fit in 64 bits.
+	ErrOverflow = errors.New("increment would overflow")
+)
+
+// IncrBy adds delta to the integer value of a key and returns the new value. A missing or expired
+// key counts as 0, and is created with ttl. An existing key keeps its time to live.
+func (c *Cache) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	if err := c.checkWrite(key, nil, ttl); err != nil {
+		return 0, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, stored, err := c.loadVersion(key)
+	if err != nil {
+		return 0, err
+	}
+	current := int64(0)
+	expiresAt := c.expiresAt(ttl)
+	if entry != nil {
+		// chunked values have no value in their entry, and aren't integers anyway
+		current, err = strconv.ParseInt(string(entry.Value), 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		expiresAt = entry.ExpiresAt
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	current += delta
+	err = c.store(key, &CacheEntry{Value: []byte(strconv.FormatInt(current, 10)), ExpiresAt: expiresAt}, stored, KeyEventType_KEY_SET)
+	if err != nil {
+		return 0, err
+	}
+	return current, nil
+}
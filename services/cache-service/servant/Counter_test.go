@@ -0,0 +1,55 @@
+package CacheServiceServant
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheIncrBy(t *testing.T) {
+	cache, _, advance := newTestCache()
+
+	if value, err := cache.IncrBy("requests", 1, time.Second); err != nil || value != 1 {
+		t.Fatalf("IncrBy() of a missing key = %v, %v, want 1", value, err)
+	}
+	if value, _ := cache.IncrBy("requests", -3, time.Hour); value != -2 {
+		t.Errorf("IncrBy() = %v, want -2", value)
+	}
+	// the ttl of the first call still applies
+	advance(time.Second)
+	if value, _ := cache.IncrBy("requests", 1, 0); value != 1 {
+		t.Errorf("IncrBy() of an expired counter = %v, want 1", value)
+	}
+	if ttl, _ := cache.TTL("requests"); ttl != TTLPersisted {
+		t.Errorf("TTL() of a counter created without ttl = %v", ttl)
+	}
+
+	cache.Set("name", []byte("alice"), 0)
+	if _, err := cache.IncrBy("name", 1, 0); err != ErrNotInteger {
+		t.Errorf("IncrBy() of a string = %v, want %v", err, ErrNotInteger)
+	}
+	cache.Set("max", []byte("9223372036854775807"), 0)
+	if _, err := cache.IncrBy("max", 1, 0); err != ErrOverflow {
+		t.Errorf("IncrBy() past MaxInt64 = %v, want %v", err, ErrOverflow)
+	}
+	if value, _ := cache.IncrBy("max", math.MinInt64, 0); value != -1 {
+		t.Errorf("IncrBy(MinInt64) = %v, want -1", value)
+	}
+}
+
+func TestCacheIncrByConcurrent(t *testing.T) {
+	cache, _, _ := newTestCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.IncrBy("counter", 2, 0)
+		}()
+	}
+	wg.Wait()
+	if value, _, _ := cache.Get("counter"); string(value) != "100" {
+		t.Errorf("counter = %v after 50 concurrent increments by 2, want 100", value)
+	}
+}
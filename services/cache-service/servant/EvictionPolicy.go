@@ -0,0 +1,261 @@
+package CacheServiceServant
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+// size of ARC's ghost lists when the local tier isn't bounded by entries
+const defaultARCGhosts = 1024
+
+// evictionPolicy picks the entries the local tier evicts when it is full.
+type evictionPolicy interface {
+	// add is called when a key enters the tier
+	add(key string)
+	// touch is called when a key is read
+	touch(key string)
+	// remove is called when a key is invalidated
+	remove(key string)
+	// evict removes the next key to evict and returns it, or false if the tier is empty
+	evict() (string, bool)
+}
+
+func newEvictionPolicy(name string, maxEntries int) (evictionPolicy, error) {
+	switch name {
+	case "", "lru":
+		return newLRU(), nil
+	case "lfu":
+		return newLFU(), nil
+	case "arc":
+		ghosts := maxEntries
+		if ghosts <= 0 {
+			ghosts = defaultARCGhosts
+		}
+		return newARC(ghosts), nil
+	}
+	return nil, fmt.Errorf("unknown eviction policy: %v", name)
+}
+
+// keyList is a list of keys ordered from the least to the most recently used
+type keyList struct {
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newKeyList() *keyList {
+	return &keyList{order: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (l *keyList) contains(key string) bool {
+	_, ok := l.elements[key]
+	return ok
+}
+
+func (l *keyList) len() int {
+	return len(l.elements)
+}
+
+// pushBack adds a key as the most recently used, or moves it there
+func (l *keyList) pushBack(key string) {
+	if element, ok := l.elements[key]; ok {
+		l.order.MoveToBack(element)
+		return
+	}
+	l.elements[key] = l.order.PushBack(key)
+}
+
+func (l *keyList) remove(key string) bool {
+	element, ok := l.elements[key]
+	if !ok {
+		return false
+	}
+	l.order.Remove(element)
+	delete(l.elements, key)
+	return true
+}
+
+// popFront removes the least recently used key
+func (l *keyList) popFront() (string, bool) {
+	element := l.order.Front()
+	if element == nil {
+		return "", false
+	}
+	key := element.Value.(string)
+	l.order.Remove(element)
+	delete(l.elements, key)
+	return key, true
+}
+
+// lru evicts the least recently used key
+type lru struct {
+	keys *keyList
+}
+
+func newLRU() *lru {
+	return &lru{keys: newKeyList()}
+}
+
+func (p *lru) add(key string)        { p.keys.pushBack(key) }
+func (p *lru) touch(key string)      { p.keys.pushBack(key) }
+func (p *lru) remove(key string)     { p.keys.remove(key) }
+func (p *lru) evict() (string, bool) { return p.keys.popFront() }
+
+type lfuItem struct {
+	key   string
+	reads int
+	// when the key was last used, to evict the least recently used of equally used keys
+	tick  int64
+	index int
+}
+
+// lfu evicts the least frequently used key. Frequencies are counted from when a key enters the tier.
+type lfu struct {
+	items map[string]*lfuItem
+	queue lfuQueue
+	tick  int64
+}
+
+func newLFU() *lfu {
+	return &lfu{items: make(map[string]*lfuItem)}
+}
+
+func (p *lfu) add(key string) {
+	p.tick++
+	if item, ok := p.items[key]; ok {
+		item.tick = p.tick
+		heap.Fix(&p.queue, item.index)
+		return
+	}
+	item := &lfuItem{key: key, tick: p.tick}
+	p.items[key] = item
+	heap.Push(&p.queue, item)
+}
+
+func (p *lfu) touch(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.tick++
+	item.reads++
+	item.tick = p.tick
+	heap.Fix(&p.queue, item.index)
+}
+
+func (p *lfu) remove(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&p.queue, item.index)
+	delete(p.items, key)
+}
+
+func (p *lfu) evict() (string, bool) {
+	if len(p.queue) == 0 {
+		return "", false
+	}
+	item := heap.Pop(&p.queue).(*lfuItem)
+	delete(p.items, item.key)
+	return item.key, true
+}
+
+type lfuQueue []*lfuItem
+
+func (q lfuQueue) Len() int { return len(q) }
+
+func (q lfuQueue) Less(i, j int) bool {
+	if q[i].reads != q[j].reads {
+		return q[i].reads < q[j].reads
+	}
+	return q[i].tick < q[j].tick
+}
+
+func (q lfuQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *lfuQueue) Push(x any) {
+	item := x.(*lfuItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *lfuQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// arc is the Adaptive Replacement Cache of Megiddo and Modha. Keys read once are in recent,
+// keys read again in frequent. The ghost lists remember recently evicted keys, and a key added
+// again after its eviction moves target, the share of recent, towards the list it was evicted from.
+type arc struct {
+	recent, frequent             *keyList
+	recentGhosts, frequentGhosts *keyList
+	// how many keys of the tier should be in recent
+	target int
+	// how many keys each ghost list remembers
+	ghosts int
+}
+
+func newARC(ghosts int) *arc {
+	return &arc{
+		recent:         newKeyList(),
+		frequent:       newKeyList(),
+		recentGhosts:   newKeyList(),
+		frequentGhosts: newKeyList(),
+		ghosts:         ghosts,
+	}
+}
+
+func (p *arc) add(key string) {
+	if p.recent.contains(key) || p.frequent.contains(key) {
+		p.touch(key)
+		return
+	}
+	switch {
+	case p.recentGhosts.remove(key):
+		// evicted from recent too early
+		p.target = min(p.target+max(1, p.frequentGhosts.len()/max(1, p.recentGhosts.len())), p.ghosts)
+		p.frequent.pushBack(key)
+	case p.frequentGhosts.remove(key):
+		// evicted from frequent too early
+		p.target = max(p.target-max(1, p.recentGhosts.len()/max(1, p.frequentGhosts.len())), 0)
+		p.frequent.pushBack(key)
+	default:
+		p.recent.pushBack(key)
+	}
+}
+
+func (p *arc) touch(key string) {
+	if p.recent.remove(key) || p.frequent.contains(key) {
+		p.frequent.pushBack(key)
+	}
+}
+
+func (p *arc) remove(key string) {
+	if !p.recent.remove(key) {
+		p.frequent.remove(key)
+	}
+}
+
+func (p *arc) evict() (string, bool) {
+	from, ghosts := p.frequent, p.frequentGhosts
+	if p.recent.len() > 0 && (p.recent.len() > p.target || p.frequent.len() == 0) {
+		from, ghosts = p.recent, p.recentGhosts
+	}
+	key, ok := from.popFront()
+	if !ok {
+		return "", false
+	}
+	ghosts.pushBack(key)
+	if ghosts.len() > p.ghosts {
+		ghosts.popFront()
+	}
+	return key, true
+}
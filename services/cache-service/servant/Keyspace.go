@@ -0,0 +1,83 @@
+package CacheServiceServant
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+// Keyspace fans the key events of the cache out to the subscriptions matching their keys.
+type Keyspace struct {
+	mutex         sync.Mutex
+	subscriptions map[*KeySubscription]struct{}
+}
+
+// KeySubscription receives the events of the keys matching its pattern on Events.
+// Events is closed when the subscription is canceled, or when it falls behind.
+type KeySubscription struct {
+	Events  chan *KeyEvent
+	pattern *regexp.Regexp
+	// set when Events is closed because it was full
+	overflowed bool
+}
+
+func NewKeyspace() *Keyspace {
+	return &Keyspace{subscriptions: make(map[*KeySubscription]struct{})}
+}
+
+// compilePattern converts a key pattern to a regular expression, see SubscribeRequest.key_pattern.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	return regexp.Compile("^" + expression + "$")
+}
+
+// Subscribe subscribes to the events of the keys matching pattern. Up to buffer events wait
+// for the subscriber before it is considered behind.
+func (k *Keyspace) Subscribe(pattern string, buffer int) (*KeySubscription, error) {
+	compiled, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	subscription := &KeySubscription{Events: make(chan *KeyEvent, buffer), pattern: compiled}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.subscriptions[subscription] = struct{}{}
+	return subscription, nil
+}
+
+// Unsubscribe cancels a subscription. It returns true if the subscription fell behind and was already canceled.
+func (k *Keyspace) Unsubscribe(subscription *KeySubscription) (overflowed bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if _, ok := k.subscriptions[subscription]; ok {
+		delete(k.subscriptions, subscription)
+		close(subscription.Events)
+	}
+	return subscription.overflowed
+}
+
+// Publish sends an event to the subscriptions of its key. It doesn't block: a subscription
+// whose buffer is full is canceled instead, since it would miss the event.
+func (k *Keyspace) Publish(event *KeyEvent) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for subscription := range k.subscriptions {
+		if !subscription.pattern.MatchString(event.Key) {
+			continue
+		}
+		select {
+		case subscription.Events <- event:
+		default:
+			subscription.overflowed = true
+			delete(k.subscriptions, subscription)
+			close(subscription.Events)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package CacheServiceServant
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+func TestKeyspace(t *testing.T) {
+	cache, _, advance := newTestCache()
+	keyspace := NewKeyspace()
+	cache.OnEvent = keyspace.Publish
+
+	sessions, err := keyspace.Subscribe("session:*", 10)
+	if err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	single, _ := keyspace.Subscribe("page?", 1)
+
+	cache.Set("session:alice", []byte("1"), time.Second)
+	cache.Set("page1", []byte("<html>"), 0)
+	cache.Set("page10", []byte("<html>"), 0)
+	cache.Expire("session:alice", time.Minute)
+	cache.Delete("session:alice")
+	cache.Set("session:bob", []byte("2"), time.Second)
+	advance(time.Second)
+	cache.Sweep(nil)
+
+	want := []struct {
+		eventType KeyEventType
+		key       string
+		value     string
+	}{
+		{KeyEventType_KEY_SET, "session:alice", "1"},
+		{KeyEventType_KEY_EXPIRE, "session:alice", ""},
+		{KeyEventType_KEY_DELETE, "session:alice", ""},
+		{KeyEventType_KEY_SET, "session:bob", "2"},
+		{KeyEventType_KEY_EXPIRED, "session:bob", ""},
+	}
+	if overflowed := keyspace.Unsubscribe(sessions); overflowed {
+		t.Errorf("subscription of session keys fell behind")
+	}
+	var i int
+	for event := range sessions.Events {
+		if i >= len(want) {
+			t.Fatalf("unexpected event %v", event)
+		}
+		if event.Type != want[i].eventType || event.Key != want[i].key || string(event.Value) != want[i].value {
+			t.Errorf("event %v = %v, want %v", i, event, want[i])
+		}
+		if event.Type != KeyEventType_KEY_DELETE && event.Type != KeyEventType_KEY_EXPIRED && event.Version == 0 {
+			t.Errorf("event %v has no version", event)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("got %v events, want %v", i, len(want))
+	}
+
+	// page1 filled the buffer of 1, and page10 doesn't match
+	cache.Set("page2", []byte("<html>"), 0)
+	if overflowed := keyspace.Unsubscribe(single); !overflowed {
+		t.Errorf("a full subscription wasn't canceled")
+	}
+}
+
+func TestKeyEventChunked(t *testing.T) {
+	cache, _, _ := newTestCache()
+	cache.ChunkBytes = 4
+	var events []*KeyEvent
+	cache.OnEvent = func(event *KeyEvent) { events = append(events, event) }
+
+	cache.Set("image", []byte("0123456789"), 0)
+	cache.Set("empty", []byte{}, 0)
+	cache.Set("small", []byte("1"), 0)
+	if len(events) != 3 {
+		t.Fatalf("got %v events, want 3", len(events))
+	}
+	if !events[0].Chunked || len(events[0].Value) != 0 {
+		t.Errorf("event of a chunked value = %v, want it flagged without its value", events[0])
+	}
+	if events[1].Chunked || events[2].Chunked || string(events[2].Value) != "1" {
+		t.Errorf("events of inline values = %v, %v, want them unflagged with their values", events[1], events[2])
+	}
+}
@@ -0,0 +1,192 @@
+package CacheServiceServant
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+type LocalTierConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// eviction policy: lru (default), lfu or arc
+	Policy string `yaml:"policy"`
+	// bounds of the tier, 0 for no bound
+	MaxEntries int   `yaml:"maxEntries"`
+	MaxBytes   int64 `yaml:"maxBytes"`
+	// how long an entry is kept, 0 to keep it until it is evicted or invalidated.
+	// Bounds how stale an entry gets when the event of its change is missed
+	MaxAgeMs int64 `yaml:"maxAgeMs"`
+}
+
+// LocalTier keeps recently used entries of a node in memory, so reads don't go to the ring.
+// Entries are written through by the node's own writes, and invalidated when other nodes write.
+type LocalTier struct {
+	mutex   sync.Mutex
+	config  LocalTierConfig
+	policy  evictionPolicy
+	entries map[string]*CacheEntry
+	// when entries were added, for MaxAgeMs
+	added map[string]time.Time
+	bytes int64
+	// incremented by every invalidation, see Fill
+	generation uint64
+
+	hits, misses, evictions uint64
+
+	now func() time.Time
+}
+
+func NewLocalTier(config LocalTierConfig) (*LocalTier, error) {
+	policy, err := newEvictionPolicy(config.Policy, config.MaxEntries)
+	if err != nil {
+		return nil, err
+	}
+	if config.Policy == "" {
+		config.Policy = "lru"
+	}
+	return &LocalTier{
+		config:  config,
+		policy:  policy,
+		entries: make(map[string]*CacheEntry),
+		added:   make(map[string]time.Time),
+		now:     time.Now,
+	}, nil
+}
+
+func entrySize(key string, entry *CacheEntry) int64 {
+	return int64(len(key) + len(entry.Value))
+}
+
+// Get returns the entry of a key, and counts the read as a hit or a miss.
+// Entries older than MaxAgeMs are dropped and count as misses.
+func (t *LocalTier) Get(key string) (*CacheEntry, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entry, ok := t.entries[key]
+	if ok && t.config.MaxAgeMs > 0 && t.now().Sub(t.added[key]) >= time.Duration(t.config.MaxAgeMs)*time.Millisecond {
+		t.drop(key)
+		ok = false
+	}
+	if !ok {
+		t.misses++
+		return nil, false
+	}
+	t.hits++
+	t.policy.touch(key)
+	return entry, true
+}
+
+// Generation returns the tier's invalidation count. Read it before loading an entry from the ring.
+func (t *LocalTier) Generation() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.generation
+}
+
+// Fill adds an entry read from the ring, unless a key was invalidated since generation was read:
+// the entry may be older than the write that invalidated it.
+func (t *LocalTier) Fill(key string, entry *CacheEntry, generation uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.generation != generation {
+		return
+	}
+	t.put(key, entry)
+}
+
+// Put adds or replaces the entry of a key, written by this node.
+func (t *LocalTier) Put(key string, entry *CacheEntry) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.put(key, entry)
+}
+
+// put makes room for an entry before adding it, so a new entry isn't its own victim.
+func (t *LocalTier) put(key string, entry *CacheEntry) {
+	size := entrySize(key, entry)
+	if t.config.MaxBytes > 0 && size > t.config.MaxBytes {
+		t.drop(key)
+		return
+	}
+	old, replaced := t.entries[key]
+	if replaced {
+		t.bytes -= entrySize(key, old)
+		delete(t.entries, key)
+	}
+	for t.exceeds(size) {
+		victim, ok := t.policy.evict()
+		if !ok {
+			break
+		}
+		if victim == key {
+			// the replaced entry
+			replaced = false
+			continue
+		}
+		t.bytes -= entrySize(victim, t.entries[victim])
+		delete(t.entries, victim)
+		delete(t.added, victim)
+		t.evictions++
+	}
+	if replaced {
+		t.policy.touch(key)
+	} else {
+		t.policy.add(key)
+	}
+	t.entries[key] = entry
+	t.added[key] = t.now()
+	t.bytes += size
+}
+
+// exceeds tells whether adding an entry of size bytes would exceed the bounds of the tier
+func (t *LocalTier) exceeds(size int64) bool {
+	return (t.config.MaxEntries > 0 && len(t.entries)+1 > t.config.MaxEntries) ||
+		(t.config.MaxBytes > 0 && t.bytes+size > t.config.MaxBytes)
+}
+
+// Invalidate drops the entry of a key, changed by this or another node.
+func (t *LocalTier) Invalidate(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.generation++
+	t.drop(key)
+}
+
+// Clear drops every entry, when the changes of keys may have been missed.
+func (t *LocalTier) Clear() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.generation++
+	for key := range t.entries {
+		t.drop(key)
+	}
+}
+
+func (t *LocalTier) drop(key string) {
+	entry, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	t.bytes -= entrySize(key, entry)
+	delete(t.entries, key)
+	delete(t.added, key)
+	t.policy.remove(key)
+}
+
+func (t *LocalTier) Stats() *LocalTierStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	stats := &LocalTierStats{
+		Policy:    t.config.Policy,
+		Hits:      t.hits,
+		Misses:    t.misses,
+		Evictions: t.evictions,
+		Entries:   int64(len(t.entries)),
+		Bytes:     t.bytes,
+	}
+	if reads := t.hits + t.misses; reads > 0 {
+		stats.HitRate = float64(t.hits) / float64(reads)
+	}
+	return stats
+}
@@ -0,0 +1,144 @@
+package CacheServiceServant
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+func newTestTier(t *testing.T, config LocalTierConfig) *LocalTier {
+	tier, err := NewLocalTier(config)
+	if err != nil {
+		t.Fatalf("NewLocalTier() failed: %v", err)
+	}
+	return tier
+}
+
+func TestLocalTierEviction(t *testing.T) {
+	tests := []struct {
+		policy string
+		// keys read after a, b and c are added, before d is
+		reads []string
+		// the key d evicts
+		evicted string
+	}{
+		{policy: "lru", reads: []string{"a", "c"}, evicted: "b"},
+		{policy: "lfu", reads: []string{"a", "a", "b", "c", "c"}, evicted: "b"},
+		// c is read twice, so it is in frequent, and a and b were read once
+		{policy: "arc", reads: []string{"c"}, evicted: "a"},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			tier := newTestTier(t, LocalTierConfig{Policy: test.policy, MaxEntries: 3})
+			for _, key := range []string{"a", "b", "c"} {
+				tier.Put(key, &CacheEntry{Value: []byte(key)})
+			}
+			for _, key := range test.reads {
+				tier.Get(key)
+			}
+			tier.Put("d", &CacheEntry{Value: []byte("d")})
+			if _, ok := tier.Get(test.evicted); ok {
+				t.Errorf("%v wasn't evicted", test.evicted)
+			}
+			if stats := tier.Stats(); stats.Entries != 3 || stats.Evictions != 1 {
+				t.Errorf("stats = %v, want 3 entries and 1 eviction", stats)
+			}
+		})
+	}
+}
+
+func TestLocalTierBytes(t *testing.T) {
+	tier := newTestTier(t, LocalTierConfig{MaxBytes: 10})
+	tier.Put("a", &CacheEntry{Value: []byte("1234")})
+	tier.Put("b", &CacheEntry{Value: []byte("1234")})
+	if stats := tier.Stats(); stats.Bytes != 10 || stats.Evictions != 0 {
+		t.Errorf("stats = %v, want 10 bytes and no evictions", stats)
+	}
+	tier.Put("c", &CacheEntry{Value: []byte("1")})
+	if _, ok := tier.Get("a"); ok {
+		t.Errorf("a wasn't evicted")
+	}
+	// larger than the tier
+	tier.Put("d", &CacheEntry{Value: []byte("12345678901")})
+	if _, ok := tier.Get("d"); ok {
+		t.Errorf("an entry larger than the tier was added")
+	}
+	if stats := tier.Stats(); stats.Bytes != 7 || stats.Entries != 2 {
+		t.Errorf("stats = %v, want 7 bytes in 2 entries", stats)
+	}
+}
+
+func TestLocalTierFillAfterInvalidate(t *testing.T) {
+	tier := newTestTier(t, LocalTierConfig{Policy: "lfu", MaxEntries: 10})
+	generation := tier.Generation()
+	// another node wrote the key while it was read from the ring
+	tier.Invalidate("a")
+	tier.Fill("a", &CacheEntry{Value: []byte("old")}, generation)
+	if _, ok := tier.Get("a"); ok {
+		t.Errorf("a stale entry was filled")
+	}
+	tier.Fill("a", &CacheEntry{Value: []byte("new")}, tier.Generation())
+	if entry, ok := tier.Get("a"); !ok || string(entry.Value) != "new" {
+		t.Errorf("Get(a) = %v, %v, want new", entry, ok)
+	}
+}
+
+func TestCacheLocalTier(t *testing.T) {
+	cache, ring, advance := newTestCache()
+	cache.Local = newTestTier(t, LocalTierConfig{Policy: "arc", MaxEntries: 10})
+	var written []string
+	cache.OnEvent = func(event *KeyEvent) { written = append(written, event.Key) }
+
+	cache.Set("a", []byte("1"), time.Second)
+	// written through, so it isn't read from the ring
+	ring.Delete("a")
+	if value, ok, _ := cache.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("Get(a) = %q, %v, want the written value", value, ok)
+	}
+	advance(time.Second)
+	if _, ok, _ := cache.Get("a"); ok {
+		t.Errorf("Get(a) returned an expired entry")
+	}
+
+	ring.Set("b", `{"value":"Mg=="}`)
+	cache.Get("b")
+	ring.Set("b", `{"value":"Mw=="}`)
+	if value, _, _ := cache.Get("b"); string(value) != "2" {
+		t.Errorf("Get(b) = %q, want it read from the local tier", value)
+	}
+	cache.Invalidate("b")
+	if value, _, _ := cache.Get("b"); string(value) != "3" {
+		t.Errorf("Get(b) = %q after an invalidation, want 3", value)
+	}
+	cache.Delete("b")
+	if _, ok, _ := cache.Get("b"); ok {
+		t.Errorf("Get(b) returned a deleted entry")
+	}
+
+	if len(written) != 2 || written[0] != "a" || written[1] != "b" {
+		t.Errorf("OnEvent got %v, want a and b", written)
+	}
+	if stats := cache.Local.Stats(); stats.Hits != 3 || stats.Misses != 3 {
+		t.Errorf("stats = %v, want 3 hits and 3 misses", stats)
+	}
+}
+
+func TestLocalTierMaxAge(t *testing.T) {
+	tier := newTestTier(t, LocalTierConfig{MaxAgeMs: 1000})
+	now := time.UnixMilli(1_000_000)
+	tier.now = func() time.Time { return now }
+	tier.Put("a", &CacheEntry{Value: []byte("1")})
+	now = now.Add(500 * time.Millisecond)
+	tier.Put("b", &CacheEntry{Value: []byte("2")})
+	now = now.Add(500 * time.Millisecond)
+	if _, ok := tier.Get("a"); ok {
+		t.Errorf("a was kept for longer than MaxAgeMs")
+	}
+	if _, ok := tier.Get("b"); !ok {
+		t.Errorf("b was dropped before MaxAgeMs")
+	}
+	if stats := tier.Stats(); stats.Entries != 1 || stats.Bytes != 2 || stats.Misses != 1 {
+		t.Errorf("stats = %v, want 1 entry of 2 bytes and 1 miss", stats)
+	}
+}
@@ -0,0 +1,33 @@
+package CacheServiceServant
+
+import (
+	"hash/fnv"
+)
+
+// Owner returns the node that serializes the changes of a key, by rendezvous hashing: the node
+// whose hash with the key is the highest. When a node joins or leaves, only the keys it owns move.
+func Owner(key string, nodes []string) string {
+	var owner string
+	var highest uint64
+	for _, node := range nodes {
+		h := fnv.New64a()
+		h.Write([]byte(node))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if score := mix(h.Sum64()); owner == "" || score > highest || (score == highest && node < owner) {
+			owner, highest = node, score
+		}
+	}
+	return owner
+}
+
+// mix spreads the bits of an FNV hash, the finalizer of MurmurHash3. Without it, nodes whose
+// addresses only differ in their port rank the same way for most keys, and own most of them.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
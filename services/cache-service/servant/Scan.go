@@ -0,0 +1,132 @@
+package CacheServiceServant
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+// page sizes of Scan
+const (
+	DefaultScanLimit = 100
+	MaxScanLimit     = 1000
+)
+
+// how long the keys left to scan after a cursor are kept for the next page, and how many scans are kept
+const (
+	scanSnapshotTTL  = 30 * time.Second
+	maxScanSnapshots = 64
+)
+
+// ErrInvalidCursor is returned by Scan for a cursor it didn't return.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// scanSnapshot is the sorted keys left to scan after a cursor.
+type scanSnapshot struct {
+	keys    []string
+	expires time.Time
+}
+
+// Scan returns up to limit live keys starting with prefix, after the key cursor points at, in ascending order,
+// and the cursor of the next page, empty after the last page. Cursors are the last key of their page.
+// Reading the keys of the ring and sorting them is the cost of a page, so the keys left after the cursor
+// are kept for scanSnapshotTTL, and the next page reads them if it comes to this node. Otherwise it reads
+// the ring again, so a scan doesn't depend on state kept between pages. Every key of a page is read
+// again, so keys deleted since the keys were kept are skipped, but keys added since aren't returned.
+func (c *Cache) Scan(prefix, cursor string, limit int, withValues bool) ([]*KeyResult, string, error) {
+	if limit <= 0 {
+		limit = DefaultScanLimit
+	}
+	limit = min(limit, MaxScanLimit)
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	matching, ok := c.takeScanSnapshot(prefix, cursor)
+	if !ok {
+		keys, err := c.ring.GetAllKeys()
+		if err != nil {
+			return nil, "", err
+		}
+		matching = keys[:0:0]
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) && key > string(after) && checkKey(key) == nil {
+				matching = append(matching, key)
+			}
+		}
+		sort.Strings(matching)
+	}
+
+	var page []*KeyResult
+	for i, key := range matching {
+		if len(page) == limit {
+			// the page ends at the key before
+			next := base64.RawURLEncoding.EncodeToString([]byte(matching[i-1]))
+			c.keepScanSnapshot(prefix, next, matching[i:])
+			return page, next, nil
+		}
+		result, err := c.scanKey(key, withValues)
+		if err != nil {
+			return nil, "", err
+		}
+		if result != nil {
+			page = append(page, result)
+		}
+	}
+	return page, "", nil
+}
+
+// scanKey returns the result of a key, or nil if it is missing or expired.
+func (c *Cache) scanKey(key string, withValue bool) (*KeyResult, error) {
+	if withValue {
+		entry, err := c.GetEntry(key)
+		if err != nil || entry == nil {
+			return nil, err
+		}
+		return &KeyResult{Key: key, Value: entry.Value, Version: entry.Version, Found: true}, nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, err := c.loadLive(key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return &KeyResult{Key: key, Found: true}, nil
+}
+
+// takeScanSnapshot returns the keys left to scan after a cursor, if they are still kept.
+// The next page keeps the keys left after it, under its own cursor.
+func (c *Cache) takeScanSnapshot(prefix, cursor string) ([]string, bool) {
+	c.scanMutex.Lock()
+	defer c.scanMutex.Unlock()
+	snapshot, ok := c.scans[prefix+"\x00"+cursor]
+	if !ok {
+		return nil, false
+	}
+	delete(c.scans, prefix+"\x00"+cursor)
+	return snapshot.keys, c.now().Before(snapshot.expires)
+}
+
+// keepScanSnapshot keeps the keys left to scan after a cursor for the next page, unless too many scans are kept.
+func (c *Cache) keepScanSnapshot(prefix, cursor string, keys []string) {
+	c.scanMutex.Lock()
+	defer c.scanMutex.Unlock()
+	now := c.now()
+	for id, snapshot := range c.scans {
+		if !now.Before(snapshot.expires) {
+			delete(c.scans, id)
+		}
+	}
+	if len(c.scans) >= maxScanSnapshots {
+		return
+	}
+	if c.scans == nil {
+		c.scans = make(map[string]*scanSnapshot)
+	}
+	c.scans[prefix+"\x00"+cursor] = &scanSnapshot{keys: keys, expires: now.Add(scanSnapshotTTL)}
+}
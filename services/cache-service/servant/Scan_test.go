@@ -0,0 +1,85 @@
+package CacheServiceServant
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCacheScan(t *testing.T) {
+	cache, _, advance := newTestCache()
+	cache.ChunkBytes = 2
+	for i := 0; i < 25; i++ {
+		cache.Set(fmt.Sprintf("tenant1:%02d", i), []byte(fmt.Sprint(i)), 0)
+	}
+	cache.Set("tenant1:expired", []byte("x"), time.Second)
+	cache.Set("tenant2:00", []byte("y"), 0)
+	advance(time.Second)
+
+	var keys []string
+	cursor := ""
+	pages := 0
+	for {
+		page, next, err := cache.Scan("tenant1:", cursor, 10, true)
+		if err != nil {
+			t.Fatalf("Scan() failed: %v", err)
+		}
+		pages++
+		for _, result := range page {
+			if string(result.Value) != fmt.Sprint(len(keys)) {
+				t.Errorf("Scan() returned %v with value %q", result.Key, result.Value)
+			}
+			keys = append(keys, result.Key)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	// the chunks of the values, the expired key and tenant2 are skipped
+	if len(keys) != 25 || pages != 3 || keys[0] != "tenant1:00" || keys[24] != "tenant1:24" {
+		t.Errorf("Scan() returned %v keys in %v pages: %v", len(keys), pages, keys)
+	}
+
+	if _, _, err := cache.Scan("", "not a cursor!", 0, false); err != ErrInvalidCursor {
+		t.Errorf("Scan() with an invalid cursor = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+// listingRing is a mapRing counting how many times all its keys are read
+type listingRing struct {
+	*mapRing
+	listed int
+}
+
+func (r *listingRing) GetAllKeys() ([]string, error) {
+	r.listed++
+	return r.mapRing.GetAllKeys()
+}
+
+func TestCacheScanSnapshot(t *testing.T) {
+	ring := &listingRing{mapRing: newMapRing()}
+	cache := NewCache(ring)
+	now := time.UnixMilli(1_000_000)
+	cache.now = func() time.Time { return now }
+	for i := 0; i < 6; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("x"), 0)
+	}
+
+	first, cursor, _ := cache.Scan("", "", 2, false)
+	// keys set after the first page are left out of the kept keys, and deleted keys are skipped
+	cache.Set("key9", []byte("x"), 0)
+	cache.Set("key22", []byte("x"), 0)
+	cache.Delete("key2")
+	second, cursor, _ := cache.Scan("", cursor, 2, false)
+	if ring.listed != 1 || len(first) != 2 || len(second) != 2 || second[0].Key != "key3" || second[1].Key != "key4" {
+		t.Errorf("Scan() = %v after %v reads of the ring, want key3 and key4 from the kept keys", second, ring.listed)
+	}
+
+	// once the kept keys expire, the ring is read again from the cursor
+	now = now.Add(scanSnapshotTTL)
+	third, cursor, _ := cache.Scan("", cursor, 10, false)
+	if ring.listed != 2 || len(third) != 2 || third[0].Key != "key5" || third[1].Key != "key9" || cursor != "" {
+		t.Errorf("Scan() after the kept keys expired = %v after %v reads of the ring, want key5 and key9", third, ring.listed)
+	}
+}
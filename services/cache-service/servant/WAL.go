@@ -0,0 +1,430 @@
+package CacheServiceServant
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	"google.golang.org/protobuf/proto"
+)
+
+type PersistenceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// path of the log files without their extension. May contain %d, see OpenWAL
+	Path string `yaml:"path"`
+	// when appended changes are synced to disk: always, everysec (default) or never
+	Fsync string `yaml:"fsync"`
+	// how often the log is compacted into a snapshot
+	SnapshotIntervalSeconds int `yaml:"snapshotIntervalSeconds"`
+}
+
+// fsync policies of the write-ahead log
+const (
+	// every change is synced before its call returns, and the call fails if it can't be
+	FsyncAlways = "always"
+	// changes are synced once a second, and a crash loses up to a second of them
+	FsyncEverySec = "everysec"
+	// changes are synced when the operating system flushes them
+	FsyncNever = "never"
+)
+
+// how often the log is compacted when the config doesn't say
+const defaultSnapshotInterval = time.Minute
+
+// extensions of the files of a log
+const (
+	lockSuffix     = ".lock"
+	logSuffix      = ".wal"
+	oldLogSuffix   = ".wal.old"
+	snapshotSuffix = ".snapshot"
+)
+
+// WAL is the write-ahead log of a node: the changes the node makes, appended to a file and
+// compacted into a snapshot of the entries they leave, so the node can write its entries back
+// to the ring when all the nodes of the service restart, see Cache.Restore.
+// A key is restored by the nodes that changed it, and only over older versions of it. A key
+// deleted by its owner may come back from the log of a node that set it before the owner changed.
+type WAL struct {
+	mutex            sync.Mutex
+	path             string
+	lock             *os.File
+	log              *os.File
+	fsync            string
+	snapshotInterval time.Duration
+	// whether changes were appended since the last sync, under the everysec policy
+	dirty bool
+	// whether changes were appended since the log was last compacted
+	changed bool
+
+	// serializes compactions
+	compacting sync.Mutex
+	now        func() time.Time
+}
+
+// OpenWAL opens the log at config.Path and returns the entries of its snapshot and changes that didn't expire.
+// The log is locked while it is open. If Path contains %d, the node takes the first log slot (0, 1, ...)
+// no other node holds, as with JobQueueConfig.LogPath, so several nodes can run on the same machine.
+// The changes are compacted into the snapshot before OpenWAL returns.
+func OpenWAL(config PersistenceConfig) (*WAL, map[string]*CacheEntry, error) {
+	return openWAL(config, time.Now)
+}
+
+// openWAL opens a log that expires entries by the time now returns.
+func openWAL(config PersistenceConfig, now func() time.Time) (*WAL, map[string]*CacheEntry, error) {
+	switch config.Fsync {
+	case "":
+		config.Fsync = FsyncEverySec
+	case FsyncAlways, FsyncEverySec, FsyncNever:
+	default:
+		return nil, nil, fmt.Errorf("unknown fsync policy: %v", config.Fsync)
+	}
+	path, lock, err := lockWAL(config.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock write-ahead log: %v", err)
+	}
+	w := &WAL{
+		path:             path,
+		lock:             lock,
+		fsync:            config.Fsync,
+		snapshotInterval: time.Duration(config.SnapshotIntervalSeconds) * time.Second,
+		now:              now,
+	}
+	if w.snapshotInterval <= 0 {
+		w.snapshotInterval = defaultSnapshotInterval
+	}
+
+	entries, err := w.load(path+snapshotSuffix, path+oldLogSuffix, path+logSuffix)
+	if err == nil {
+		err = w.writeSnapshot(entries)
+	}
+	if err == nil {
+		err = removeIfExists(path + oldLogSuffix)
+	}
+	if err == nil {
+		w.log, err = os.OpenFile(path+logSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	}
+	if err != nil {
+		lock.Close()
+		return nil, nil, err
+	}
+	return w, entries, nil
+}
+
+func lockWAL(path string) (string, *os.File, error) {
+	if !strings.Contains(path, "%d") {
+		lock, err := lockFile(path + lockSuffix)
+		return path, lock, err
+	}
+	for slot := 0; ; slot++ {
+		slotPath := fmt.Sprintf(path, slot)
+		lock, err := lockFile(slotPath + lockSuffix)
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return slotPath, lock, err
+		}
+	}
+}
+
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// load applies the records of files in order, skipping missing files, and returns the entries that didn't expire.
+// A record torn by a crash while it was written ends its file.
+func (w *WAL) load(paths ...string) (map[string]*CacheEntry, error) {
+	entries := make(map[string]*CacheEntry)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = readRecords(file, func(record *WALRecord) { applyRecord(entries, record) })
+		file.Close()
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("%v ends with a partial record, ignoring it\n", path)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %v", path, err)
+		}
+	}
+	now := w.now().UnixMilli()
+	for key, entry := range entries {
+		if entry.ExpiresAt != 0 && now >= entry.ExpiresAt {
+			delete(entries, key)
+		}
+	}
+	return entries, nil
+}
+
+func readRecords(file *os.File, apply func(record *WALRecord)) error {
+	reader := bufio.NewReader(file)
+	for {
+		size, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return nil
+		}
+		data := make([]byte, size)
+		if err == nil {
+			_, err = io.ReadFull(reader, data)
+		}
+		record := &WALRecord{}
+		if err == nil {
+			err = proto.Unmarshal(data, record)
+		}
+		if err != nil {
+			return err
+		}
+		apply(record)
+	}
+}
+
+func applyRecord(entries map[string]*CacheEntry, record *WALRecord) {
+	switch record.Op {
+	case WALOp_WAL_SET:
+		entries[record.Key] = record.Entry
+	case WALOp_WAL_EXPIRE:
+		if entry, ok := entries[record.Key]; ok {
+			entries[record.Key] = &CacheEntry{Value: entry.Value, ExpiresAt: record.Entry.GetExpiresAt(), Version: record.Entry.GetVersion()}
+		}
+	case WALOp_WAL_DELETE:
+		delete(entries, record.Key)
+	}
+}
+
+func appendRecord(writer io.Writer, record *WALRecord) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	frame := binary.AppendUvarint(nil, uint64(len(data)))
+	_, err = writer.Write(append(frame, data...))
+	return err
+}
+
+// writeSnapshot replaces the snapshot with entries. The new snapshot is synced to disk before it replaces the old one.
+func (w *WAL) writeSnapshot(entries map[string]*CacheEntry) error {
+	temp := w.path + snapshotSuffix + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for key, entry := range entries {
+		if err = appendRecord(writer, &WALRecord{Op: WALOp_WAL_SET, Key: key, Entry: entry}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err == nil {
+		err = os.Rename(temp, w.path+snapshotSuffix)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return syncDir(filepath.Dir(w.path))
+}
+
+// syncDir syncs a directory, so the files renamed into it survive a crash
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Append appends a change to the log, and syncs it under the always policy.
+// It only fails under the always policy: the other policies may lose changes anyway, so it logs their errors.
+func (w *WAL) Append(record *WALRecord) error {
+	err := w.append(record)
+	if err != nil && w.fsync != FsyncAlways {
+		log.Printf("Failed to append the change of %s to the write-ahead log: %v\n", record.Key, err)
+		return nil
+	}
+	return err
+}
+
+func (w *WAL) append(record *WALRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := appendRecord(w.log, record); err != nil {
+		return err
+	}
+	w.changed = true
+	switch w.fsync {
+	case FsyncAlways:
+		return w.log.Sync()
+	case FsyncEverySec:
+		w.dirty = true
+	}
+	return nil
+}
+
+// Sync syncs the changes appended since the last sync under the everysec policy.
+func (w *WAL) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.log.Sync()
+}
+
+// Compact folds the changes of the log into the snapshot. Changes appended meanwhile go to a new log.
+func (w *WAL) Compact() error {
+	w.compacting.Lock()
+	defer w.compacting.Unlock()
+	rotated, err := w.rotate()
+	if err != nil || !rotated {
+		return err
+	}
+	entries, err := w.load(w.path+snapshotSuffix, w.path+oldLogSuffix)
+	if err != nil {
+		return err
+	}
+	if err := w.writeSnapshot(entries); err != nil {
+		return err
+	}
+	return removeIfExists(w.path + oldLogSuffix)
+}
+
+// rotate moves the log aside and starts a new one, and returns false if there are no changes to compact.
+// A log a failed compaction moved aside is compacted first.
+func (w *WAL) rotate() (bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if _, err := os.Stat(w.path + oldLogSuffix); err == nil {
+		return true, nil
+	}
+	if !w.changed {
+		return false, nil
+	}
+	// the changes moved aside must be on disk before the snapshot replaces them
+	if err := w.log.Sync(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(w.path+logSuffix, w.path+oldLogSuffix); err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(w.path+logSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		// appends still go to the open log
+		os.Rename(w.path+oldLogSuffix, w.path+logSuffix)
+		return false, err
+	}
+	w.log.Close()
+	w.log = file
+	w.changed = false
+	w.dirty = false
+	return true, nil
+}
+
+// Run syncs the log every second under the everysec policy, and compacts it every snapshot interval,
+// until stop is closed.
+func (w *WAL) Run(stop <-chan struct{}) {
+	syncTicker := time.NewTicker(time.Second)
+	defer syncTicker.Stop()
+	snapshotTicker := time.NewTicker(w.snapshotInterval)
+	defer snapshotTicker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-syncTicker.C:
+			if err := w.Sync(); err != nil {
+				log.Printf("Failed to sync the write-ahead log: %v\n", err)
+			}
+		case <-snapshotTicker.C:
+			if err := w.Compact(); err != nil {
+				log.Printf("Failed to compact the write-ahead log: %v\n", err)
+			}
+		}
+	}
+}
+
+// Close syncs and closes the log, and releases its lock.
+func (w *WAL) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	err := w.log.Sync()
+	w.log.Close()
+	w.lock.Close()
+	return err
+}
+
+// walRecord returns the log record of a change, or nil if it has none: the log drops expired entries anyway.
+func walRecord(event *KeyEvent, entry *CacheEntry) *WALRecord {
+	if event == nil {
+		return nil
+	}
+	switch event.Type {
+	case KeyEventType_KEY_SET:
+		return &WALRecord{Op: WALOp_WAL_SET, Key: event.Key, Entry: entry}
+	case KeyEventType_KEY_EXPIRE:
+		// entries of chunked values don't have their value, which the log already has
+		return &WALRecord{Op: WALOp_WAL_EXPIRE, Key: event.Key, Entry: &CacheEntry{ExpiresAt: entry.ExpiresAt, Version: entry.Version}}
+	case KeyEventType_KEY_DELETE:
+		return &WALRecord{Op: WALOp_WAL_DELETE, Key: event.Key}
+	}
+	return nil
+}
+
+// Restore writes entries read from the write-ahead log back to the ring, unless their key has the same
+// or a newer version, and returns how many it wrote. Restored entries aren't logged again, and have no event.
+func (c *Cache) Restore(entries map[string]*CacheEntry) (int, error) {
+	restored := 0
+	for key, entry := range entries {
+		if checkKey(key) != nil || c.expired(entry) {
+			continue
+		}
+		c.mutex.Lock()
+		stored, err := c.load(key)
+		if err == nil && (stored == nil || stored.Version < entry.Version) {
+			_, err = c.write(key, entry, stored)
+			if err == nil {
+				c.written(key, entry, nil)
+				restored++
+			}
+		}
+		c.mutex.Unlock()
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %v", key, err)
+		}
+	}
+	return restored, nil
+}
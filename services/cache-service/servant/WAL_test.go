@@ -0,0 +1,152 @@
+package CacheServiceServant
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+)
+
+func openTestWAL(t *testing.T, path string, now func() time.Time) (*WAL, map[string]*CacheEntry) {
+	wal, entries, err := openWAL(PersistenceConfig{Path: path, Fsync: FsyncAlways}, now)
+	if err != nil {
+		t.Fatalf("OpenWAL() failed: %v", err)
+	}
+	return wal, entries
+}
+
+func TestWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	cache, _, advance := newTestCache()
+	cache.ChunkBytes = 4
+	cache.Log, _ = openTestWAL(t, path, cache.now)
+
+	cache.Set("a", []byte("1"), 0)
+	cache.Set("image", []byte("0123456789"), time.Second)
+	cache.Expire("image", time.Hour)
+	cache.Set("b", []byte("2"), 0)
+	cache.Delete("b")
+	cache.Set("session", []byte("alice"), time.Second)
+	if err := cache.Log.Compact(); err != nil {
+		t.Fatalf("Compact() failed: %v", err)
+	}
+	cache.IncrBy("a", 1, 0)
+	cache.Log.Close()
+
+	advance(time.Second)
+	wal, entries := openTestWAL(t, path, cache.now)
+	defer wal.Close()
+	// entries are read from the snapshot and the log, and session expired
+	if len(entries) != 2 || string(entries["a"].GetValue()) != "2" {
+		t.Errorf("entries = %v, want a = 2 and image", entries)
+	}
+	if image := entries["image"]; string(image.GetValue()) != "0123456789" || image.GetExpiresAt() != cache.now().Add(time.Hour-time.Second).UnixMilli() {
+		t.Errorf("entries[image] = %v, want its value expiring in an hour", image)
+	}
+}
+
+func TestWALPartialRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	wal, _ := openTestWAL(t, path, time.Now)
+	wal.Append(&WALRecord{Op: WALOp_WAL_SET, Key: "a", Entry: &CacheEntry{Value: []byte("1")}})
+	// a crash while a record was written
+	wal.log.Write([]byte{100, 1, 2})
+	wal.Close()
+
+	wal, entries := openTestWAL(t, path, time.Now)
+	defer wal.Close()
+	if len(entries) != 1 || string(entries["a"].GetValue()) != "1" {
+		t.Errorf("entries = %v, want a = 1", entries)
+	}
+}
+
+func TestWALSlots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache-%d")
+	first, _ := openTestWAL(t, path, time.Now)
+	second, _ := openTestWAL(t, path, time.Now)
+	defer second.Close()
+	if first.path == second.path {
+		t.Errorf("two logs took slot %v", first.path)
+	}
+	first.Close()
+	// a restarted node takes the slot of the node that stopped
+	again, _ := openTestWAL(t, path, time.Now)
+	defer again.Close()
+	if again.path != first.path {
+		t.Errorf("log took %v, want the free slot %v", again.path, first.path)
+	}
+	if _, _, err := OpenWAL(PersistenceConfig{Path: path, Fsync: "sometimes"}); err == nil {
+		t.Errorf("OpenWAL() accepted an unknown fsync policy")
+	}
+}
+
+func TestCacheRestore(t *testing.T) {
+	cache, ring, _ := newTestCache()
+	cache.ChunkBytes = 4
+	cache.Set("newer", []byte("kept"), 0)
+	newer, _ := cache.GetEntry("newer")
+	var events int
+	cache.OnEvent = func(*KeyEvent) { events++ }
+	cache.Log, _ = openTestWAL(t, filepath.Join(t.TempDir(), "cache"), time.Now)
+	defer cache.Log.Close()
+
+	restored, err := cache.Restore(map[string]*CacheEntry{
+		"a":     {Value: []byte("0123456789"), Version: 1},
+		"newer": {Value: []byte("stale"), Version: newer.Version - 1},
+	})
+	if err != nil || restored != 1 {
+		t.Errorf("Restore() = %v, %v, want 1 entry restored", restored, err)
+	}
+	if entry, _ := cache.GetEntry("a"); string(entry.GetValue()) != "0123456789" || entry.GetVersion() != 1 {
+		t.Errorf("GetEntry(a) = %v, want the restored entry", entry)
+	}
+	if value, _, _ := cache.Get("newer"); string(value) != "kept" {
+		t.Errorf("Get(newer) = %q, want the newer value kept", value)
+	}
+	// the entry and 3 chunks of a, and newer
+	if len(ring.data) != 5 {
+		t.Errorf("ring has %v keys, want 5", len(ring.data))
+	}
+	if info, _ := os.Stat(cache.Log.path + logSuffix); events != 0 || info.Size() != 0 {
+		t.Errorf("restored entries were sent or logged again")
+	}
+}
+
+func TestWALAppendError(t *testing.T) {
+	for _, fsync := range []string{FsyncAlways, FsyncNever} {
+		t.Run(fsync, func(t *testing.T) {
+			cache, _, _ := newTestCache()
+			var events int
+			cache.OnEvent = func(event *KeyEvent) { events++ }
+			wal, _, err := openWAL(PersistenceConfig{Path: filepath.Join(t.TempDir(), "cache"), Fsync: fsync}, cache.now)
+			if err != nil {
+				t.Fatalf("OpenWAL() failed: %v", err)
+			}
+			defer wal.Close()
+			cache.Log = wal
+			// a failing disk
+			wal.log.Close()
+
+			// the change is only made if the policy doesn't need it persisted
+			err = cache.Set("a", []byte("1"), 0)
+			if fsync == FsyncAlways && err == nil {
+				t.Errorf("Set() succeeded without persisting the change under the always policy")
+			}
+			if fsync == FsyncNever && err != nil {
+				t.Errorf("Set() = %v under the never policy, want the error only logged", err)
+			}
+			value, _, _ := cache.Get("a")
+			if fsync == FsyncAlways && (value != nil || events != 0) {
+				t.Errorf("Get(a) = %q after %v events, want the change aborted", value, events)
+			}
+			if fsync == FsyncNever && (string(value) != "1" || events != 1) {
+				t.Errorf("Get(a) = %q after %v events, want the change made and sent", value, events)
+			}
+			if err := cache.Delete("a"); (err == nil) != (fsync == FsyncNever) {
+				t.Errorf("Delete() = %v under the %v policy", err, fsync)
+			}
+		})
+	}
+}
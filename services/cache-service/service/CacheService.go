@@ -8,12 +8,20 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	. "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	CacheServiceOwners "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/owners"
+	CacheServiceServant "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/servant"
+	RegistryServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/client"
+	RegistryServicePb "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/common"
 	dht "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/servant/dht"
 
 	services "github.com/TAULargeScaleWorkshop/AAG/services/common"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"gopkg.in/yaml.v2"
@@ -21,19 +29,40 @@ import (
 
 var mut sync.Mutex
 
+// how often expired entries are swept when the config doesn't say
+const defaultSweepIntervalSeconds = 10
+
+// events a Subscribe stream buffers before the subscriber is dropped
+const keyEventBuffer = 1024
+
 type cacheServiceImplementation struct {
 	UnimplementedCacheServiceServer
-	mutex sync.Mutex
-	Chord *dht.Chord
+	Chord     *dht.Chord
+	Cache     *CacheServiceServant.Cache
+	Publisher *services.Publisher
+	Keyspace  *CacheServiceServant.Keyspace
+	// forwards the changes of keys other nodes own, nil to handle every call locally
+	owners *CacheServiceOwners.KeyOwners
 }
 
 type Config struct {
-	Type            string `yaml:"type"`
-	RegistryAddress string `yaml:"registryAddress"`
-	RegNum          int    `yaml:"regNum"`
-	Port            int    `yaml:"port"` // root node port
-	ChordPort       int    `yaml:"chordPort"`
-	ChordNodeName   string `yaml:"chordNodeName"`
+	Type            string            `yaml:"type"`
+	RegistryAddress string            `yaml:"registryAddress"`
+	RegNum          int               `yaml:"regNum"`
+	Port            int               `yaml:"port"` // root node port
+	ChordPort       int               `yaml:"chordPort"`
+	ChordNodeName   string            `yaml:"chordNodeName"`
+	MQ              services.MQConfig `yaml:"mq"`
+	// how often each node deletes the expired entries of the keys it owns
+	SweepIntervalSeconds int `yaml:"sweepIntervalSeconds"`
+	// in-memory tier in front of the ring
+	LocalTier CacheServiceServant.LocalTierConfig `yaml:"localTier"`
+	// largest value the cache stores, 0 for no limit
+	MaxValueBytes int `yaml:"maxValueBytes"`
+	// values larger than chunkBytes are split across DHT keys, 0 to never split them
+	ChunkBytes int `yaml:"chunkBytes"`
+	// write-ahead log of the changes of the node, restored to the ring when it starts
+	Persistence CacheServiceServant.PersistenceConfig `yaml:"persistence"`
 }
 
 func loadConfigFromData(configData []byte) (*Config, error) {
@@ -125,53 +154,332 @@ func Start(configData []byte) error {
 	}
 	mut.Unlock()
 
+	cacheServiceImp := &cacheServiceImplementation{Chord: chord, Cache: CacheServiceServant.NewCache(chord), Keyspace: CacheServiceServant.NewKeyspace()}
+	cacheServiceImp.Cache.MaxValueBytes = config.MaxValueBytes
+	cacheServiceImp.Cache.ChunkBytes = config.ChunkBytes
+	if config.LocalTier.Enabled {
+		cacheServiceImp.Cache.Local, err = CacheServiceServant.NewLocalTier(config.LocalTier)
+		if err != nil {
+			log.Printf("Failed to create the local tier: %v", err)
+			return err
+		}
+	}
+	if config.Persistence.Enabled {
+		wal, entries, err := CacheServiceServant.OpenWAL(config.Persistence)
+		if err != nil {
+			log.Printf("Failed to open the write-ahead log: %v", err)
+			return err
+		}
+		// before the node registers, so it serves calls with its entries back in the ring
+		restored, err := cacheServiceImp.Cache.Restore(entries)
+		if err != nil {
+			log.Printf("Failed to restore the write-ahead log: %v", err)
+			wal.Close()
+			return err
+		}
+		log.Printf("Restored %v of %v entries from the write-ahead log", restored, len(entries))
+		cacheServiceImp.Cache.Log = wal
+		go wal.Run(nil)
+	}
 	bindgRPCToService := func(s grpc.ServiceRegistrar) {
-		RegisterCacheServiceServer(s, &cacheServiceImplementation{Chord: chord})
+		RegisterCacheServiceServer(s, cacheServiceImp)
 	}
 	grpcServer := grpc.NewServer()
-	RegisterCacheServiceServer(grpcServer, &cacheServiceImplementation{Chord: chord})
+	RegisterCacheServiceServer(grpcServer, cacheServiceImp)
 
 	newAddress := services.Start(serviceName, newPort, bindgRPCToService)
-	unregister := services.RegisterAddress(serviceName, registryAddresses, newAddress)
+	registryClient := RegistryServiceClient.NewRegistryServiceClient(registryAddresses)
+	// calls only arrive once the node registers below
+	cacheServiceImp.owners = CacheServiceOwners.NewKeyOwners(newAddress, func() ([]string, error) {
+		return registryClient.DiscoverEndpoints(serviceName, RegistryServicePb.ProtocolGRPC)
+	}, cacheServiceImp.Cache.Invalidate)
+	// MQ setup
+	startMQ, mqAddress := services.BindMQToService(0, config.MQ, services.NewMQDispatcher(&CacheService_ServiceDesc, cacheServiceImp))
+	// nodes publish the changes they make, for the local tiers and the subscribers of the other nodes
+	var pubAddress string
+	cacheServiceImp.Publisher, pubAddress = services.NewPublisher(0)
+	cacheServiceImp.Cache.OnEvent = func(event *KeyEvent) {
+		event.Node = newAddress
+		cacheServiceImp.Keyspace.Publish(event)
+		err := cacheServiceImp.Publisher.Publish(KeyspaceTopic, event)
+		if err != nil {
+			log.Printf("Failed to publish the event of %s: %v", event.Key, err)
+		}
+	}
+	subscriber, err := services.NewSubscriber(func() ([]string, error) {
+		return registryClient.DiscoverEndpoints(serviceName, RegistryServicePb.ProtocolPub)
+	})
+	if err == nil {
+		err = services.OnEvent(subscriber, KeyspaceTopic, func(topic string, event *KeyEvent) {
+			// the events of this node were handled when it made the change
+			if event.Node != newAddress {
+				cacheServiceImp.Cache.Invalidate(event.Key)
+				cacheServiceImp.Keyspace.Publish(event)
+			}
+		})
+	}
+	if err == nil && cacheServiceImp.Cache.Local != nil {
+		// the changes nodes made before the subscriber connected to them weren't invalidated
+		subscriber.OnConnect(cacheServiceImp.Cache.Local.Clear)
+	}
+	if err != nil {
+		if cacheServiceImp.Cache.Local != nil {
+			log.Printf("Failed to subscribe to the events of the other nodes: %v", err)
+			return err
+		}
+		log.Printf("Failed to subscribe to the events of the other nodes, Subscribe only streams the changes of this node: %v", err)
+	}
+
+	unregister := services.RegisterInstance(serviceName, registryAddresses, map[string]string{
+		RegistryServicePb.ProtocolGRPC: newAddress,
+		RegistryServicePb.ProtocolMQ:   mqAddress,
+		RegistryServicePb.ProtocolPub:  pubAddress,
+	})
 
 	if unregister == nil {
 		log.Fatalf("Failed to register the service\n")
 	}
 	log.Printf("CacheService registered at %v", newAddress)
 
+	go startMQ()
+
+	sweepInterval := config.SweepIntervalSeconds
+	if sweepInterval <= 0 {
+		sweepInterval = defaultSweepIntervalSeconds
+	}
+	// every node sweeps the keys it owns, serialized with their writes
+	go cacheServiceImp.Cache.RunSweeper(time.Duration(sweepInterval)*time.Second, cacheServiceImp.owners.Owns, nil)
+
 	return nil
 }
 
+// cacheError converts the errors of the cache to gRPC errors.
+func cacheError(err error) error {
+	switch err {
+	case CacheServiceServant.ErrInvalidTTL, CacheServiceServant.ErrValueTooLarge, CacheServiceServant.ErrReservedKey,
+		CacheServiceServant.ErrInvalidCursor:
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	case CacheServiceServant.ErrVersionMismatch, CacheServiceServant.ErrNotInteger, CacheServiceServant.ErrOverflow:
+		return status.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+	return err
+}
+
 func (c *cacheServiceImplementation) Set(ctx context.Context, req *StoreKeyValue) (*emptypb.Empty, error) {
-	c.mutex.Lock()
-	err := c.Chord.Set(req.Key, req.Value)
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Key, func(ctx context.Context, owner CacheServiceClient) (*emptypb.Empty, error) {
+		return owner.Set(ctx, req)
+	}); forwarded {
+		return resp, err
+	}
+	err := c.Cache.Set(req.Key, req.Value, time.Duration(req.TtlMs)*time.Millisecond)
 	if err != nil {
-		return nil, err
+		return nil, cacheError(err)
 	}
-	c.mutex.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
-func (c *cacheServiceImplementation) Get(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
-	c.mutex.Lock()
-	value, err := c.Chord.Get(req.Value)
+// Get returns an empty value for missing and expired keys.
+func (c *cacheServiceImplementation) Get(ctx context.Context, req *wrapperspb.StringValue) (*VersionedValue, error) {
+	entry, err := c.Cache.GetEntry(req.Value)
 	if err != nil {
 		return nil, err
 	}
-	c.mutex.Unlock()
-	return wrapperspb.String(value), nil
+	if entry == nil {
+		return &VersionedValue{}, nil
+	}
+	return &VersionedValue{Value: entry.Value, Version: entry.Version, Found: true, ExpiresAt: entry.ExpiresAt}, nil
 }
 
 func (c *cacheServiceImplementation) Delete(ctx context.Context, req *wrapperspb.StringValue) (*emptypb.Empty, error) {
-	c.mutex.Lock()
-	err := c.Chord.Delete(req.Value)
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Value, func(ctx context.Context, owner CacheServiceClient) (*emptypb.Empty, error) {
+		return owner.Delete(ctx, req)
+	}); forwarded {
+		return resp, err
+	}
+	err := c.Cache.Delete(req.Value)
 	if err != nil {
 		return nil, err
 	}
-	c.mutex.Unlock()
 	return &emptypb.Empty{}, nil
 }
 
+func (c *cacheServiceImplementation) CompareAndSet(ctx context.Context, req *CompareAndSetRequest) (*wrapperspb.Int64Value, error) {
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Key, func(ctx context.Context, owner CacheServiceClient) (*wrapperspb.Int64Value, error) {
+		return owner.CompareAndSet(ctx, req)
+	}); forwarded {
+		return resp, err
+	}
+	version, err := c.Cache.CompareAndSet(req.Key, req.ExpectedVersion, req.Value, time.Duration(req.TtlMs)*time.Millisecond)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	return wrapperspb.Int64(version), nil
+}
+
+func (c *cacheServiceImplementation) SetIfAbsent(ctx context.Context, req *StoreKeyValue) (*wrapperspb.BoolValue, error) {
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Key, func(ctx context.Context, owner CacheServiceClient) (*wrapperspb.BoolValue, error) {
+		return owner.SetIfAbsent(ctx, req)
+	}); forwarded {
+		return resp, err
+	}
+	ok, err := c.Cache.SetIfAbsent(req.Key, req.Value, time.Duration(req.TtlMs)*time.Millisecond)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	return wrapperspb.Bool(ok), nil
+}
+
+func (c *cacheServiceImplementation) Incr(ctx context.Context, req *CounterRequest) (*wrapperspb.Int64Value, error) {
+	return c.incrBy(ctx, req, 1)
+}
+
+func (c *cacheServiceImplementation) Decr(ctx context.Context, req *CounterRequest) (*wrapperspb.Int64Value, error) {
+	return c.incrBy(ctx, req, -1)
+}
+
+func (c *cacheServiceImplementation) IncrBy(ctx context.Context, req *CounterRequest) (*wrapperspb.Int64Value, error) {
+	return c.incrBy(ctx, req, req.Delta)
+}
+
+// incrBy forwards Incr, Decr and IncrBy as IncrBy calls.
+func (c *cacheServiceImplementation) incrBy(ctx context.Context, req *CounterRequest, delta int64) (*wrapperspb.Int64Value, error) {
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Key, func(ctx context.Context, owner CacheServiceClient) (*wrapperspb.Int64Value, error) {
+		return owner.IncrBy(ctx, &CounterRequest{Key: req.Key, Delta: delta, TtlMs: req.TtlMs})
+	}); forwarded {
+		return resp, err
+	}
+	value, err := c.Cache.IncrBy(req.Key, delta, time.Duration(req.TtlMs)*time.Millisecond)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	return wrapperspb.Int64(value), nil
+}
+
+func (c *cacheServiceImplementation) Expire(ctx context.Context, req *ExpireRequest) (*wrapperspb.BoolValue, error) {
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Key, func(ctx context.Context, owner CacheServiceClient) (*wrapperspb.BoolValue, error) {
+		return owner.Expire(ctx, req)
+	}); forwarded {
+		return resp, err
+	}
+	ok, err := c.Cache.Expire(req.Key, time.Duration(req.TtlMs)*time.Millisecond)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	return wrapperspb.Bool(ok), nil
+}
+
+func (c *cacheServiceImplementation) TTL(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.Int64Value, error) {
+	ttl, err := c.Cache.TTL(req.Value)
+	if err != nil {
+		return nil, err
+	}
+	return wrapperspb.Int64(ttl.Milliseconds()), nil
+}
+
+func (c *cacheServiceImplementation) Persist(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.BoolValue, error) {
+	if resp, forwarded, err := CacheServiceOwners.Forward(ctx, c.owners, req.Value, func(ctx context.Context, owner CacheServiceClient) (*wrapperspb.BoolValue, error) {
+		return owner.Persist(ctx, req)
+	}); forwarded {
+		return resp, err
+	}
+	ok, err := c.Cache.Persist(req.Value)
+	if err != nil {
+		return nil, err
+	}
+	return wrapperspb.Bool(ok), nil
+}
+
+func (c *cacheServiceImplementation) MGet(ctx context.Context, req *Keys) (*KeyResults, error) {
+	return &KeyResults{Results: c.Cache.MGet(req.Keys)}, nil
+}
+
+func (c *cacheServiceImplementation) MSet(ctx context.Context, req *MSetRequest) (*KeyResults, error) {
+	keys := make([]string, len(req.Entries))
+	for i, entry := range req.Entries {
+		keys[i] = entry.Key
+	}
+	results := make([]*KeyResult, len(keys))
+	local := CacheServiceOwners.ForwardBatch(ctx, c.owners, keys, results, func(ctx context.Context, owner CacheServiceClient, indexes []int) ([]*KeyResult, error) {
+		forwarded := &MSetRequest{}
+		for _, i := range indexes {
+			forwarded.Entries = append(forwarded.Entries, req.Entries[i])
+		}
+		resp, err := owner.MSet(ctx, forwarded)
+		return resp.GetResults(), err
+	})
+	entries := make([]*StoreKeyValue, len(local))
+	for j, i := range local {
+		entries[j] = req.Entries[i]
+	}
+	for j, result := range c.Cache.MSet(entries) {
+		results[local[j]] = result
+	}
+	return &KeyResults{Results: results}, nil
+}
+
+func (c *cacheServiceImplementation) MDelete(ctx context.Context, req *Keys) (*KeyResults, error) {
+	results := make([]*KeyResult, len(req.Keys))
+	local := CacheServiceOwners.ForwardBatch(ctx, c.owners, req.Keys, results, func(ctx context.Context, owner CacheServiceClient, indexes []int) ([]*KeyResult, error) {
+		forwarded := &Keys{}
+		for _, i := range indexes {
+			forwarded.Keys = append(forwarded.Keys, req.Keys[i])
+		}
+		resp, err := owner.MDelete(ctx, forwarded)
+		return resp.GetResults(), err
+	})
+	keys := make([]string, len(local))
+	for j, i := range local {
+		keys[j] = req.Keys[i]
+	}
+	for j, result := range c.Cache.MDelete(keys) {
+		results[local[j]] = result
+	}
+	return &KeyResults{Results: results}, nil
+}
+
+func (c *cacheServiceImplementation) Scan(ctx context.Context, req *ScanRequest) (*ScanResponse, error) {
+	keys, next, err := c.Cache.Scan(req.Prefix, req.Cursor, int(req.Limit), req.WithValues)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	return &ScanResponse{Keys: keys, NextCursor: next}, nil
+}
+
+// Subscribe streams the events of the matching keys. A subscriber that falls behind by keyEventBuffer
+// events is dropped, since it would miss events.
+func (c *cacheServiceImplementation) Subscribe(req *SubscribeRequest, stream CacheService_SubscribeServer) error {
+	subscription, err := c.Keyspace.Subscribe(req.KeyPattern, keyEventBuffer)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid key pattern: %v", err)
+	}
+	defer c.Keyspace.Unsubscribe(subscription)
+	// tells the subscriber that events are streamed from now on
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return status.Errorf(codes.ResourceExhausted, "subscriber fell behind by %v events", keyEventBuffer)
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// LocalStats returns empty statistics if the local tier is disabled.
+func (c *cacheServiceImplementation) LocalStats(ctx context.Context, _ *emptypb.Empty) (*LocalTierStats, error) {
+	if c.Cache.Local == nil {
+		return &LocalTierStats{}, nil
+	}
+	return c.Cache.Local.Stats(), nil
+}
+
 func (c *cacheServiceImplementation) IsAlive(ctx context.Context, _ *emptypb.Empty) (*wrapperspb.BoolValue, error) {
 	_, err := c.Chord.IsFirst()
 	if err != nil {
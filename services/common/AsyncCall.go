@@ -0,0 +1,309 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// MQConnector is implemented by every service client (through ServiceClientBase)
+// and returns the connection to the MQ nodes of its service.
+type MQConnector interface {
+	ConnectMQ() (*MQConnection, error)
+}
+
+// Future holds the result of an asynchronous call that may not have arrived yet.
+type Future[T any] struct {
+	done   chan struct{}
+	resp   T
+	err    error
+	cancel context.CancelFunc
+}
+
+// Get blocks until the call completes and returns its response or error.
+// Errors reported by the service are returned as *CallError.
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.resp, f.err
+}
+
+// Done returns a channel that is closed once the call completed.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel abandons the call. A pending Get returns context.Canceled.
+func (f *Future[T]) Cancel() {
+	f.cancel()
+}
+
+// CallAsync calls method of the given service over the MQ channel and returns immediately.
+// The call is abandoned when ctx is cancelled or Cancel is called on the returned future.
+// ctx's deadline and outgoing metadata are sent along with the call.
+//
+//	f := CallAsync[*emptypb.Empty, *wrapperspb.StringValue](ctx, client, "HelloWorld", &emptypb.Empty{})
+//	res, err := f.Get()
+func CallAsync[Req, Resp proto.Message](ctx context.Context, service MQConnector, method string, req Req) *Future[Resp] {
+	return startAsync(ctx, func(ctx context.Context) (Resp, error) {
+		var empty Resp
+		ret, err := callMQ(ctx, service, method, req)
+		if err != nil {
+			return empty, err
+		}
+		resp := newMessage[Resp]()
+		err = unmarshalReturnValue(ret, resp)
+		if err != nil {
+			return empty, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		return resp, nil
+	})
+}
+
+// Stream receives the messages of a server-streaming call as the method sends them.
+type Stream[Resp proto.Message] struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	conn      *MQConnection
+	requestID string
+	replies   *replyQueue
+	err       error
+}
+
+// CallStream calls a server-streaming method over the MQ channel and returns its stream.
+// The call is abandoned when ctx is cancelled or Cancel is called on the stream.
+//
+//	stream, err := CallStream[*wrapperspb.Int32Value, *wrapperspb.Int32Value](ctx, client, "WaitAndRand", wrapperspb.Int32(3))
+//	for {
+//		msg, err := stream.Recv()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+func CallStream[Req, Resp proto.Message](ctx context.Context, service MQConnector, method string, req Req) (*Stream[Resp], error) {
+	conn, err := service.ConnectMQ()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQ: %w", err)
+	}
+	parameters, err := newCallParameters(ctx, method, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+	replies, err := conn.send(parameters, true)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Stream[Resp]{ctx: ctx, cancel: cancel, conn: conn, requestID: parameters.RequestId, replies: replies}, nil
+}
+
+// Recv blocks until the next message of the stream arrives. It returns io.EOF once the stream ended,
+// and the call's error if it failed. Errors reported by the service are returned as *CallError.
+func (s *Stream[Resp]) Recv() (Resp, error) {
+	var empty Resp
+	if s.err != nil {
+		return empty, s.err
+	}
+	rv, ok, err := s.replies.pop(s.ctx)
+	switch {
+	case err != nil:
+		s.err = err
+	case !ok:
+		s.err = fmt.Errorf("MQ connection closed before the stream ended")
+	default:
+		s.err = errorFromReturnValue(rv)
+		if s.err == nil && rv.EndOfStream {
+			s.err = io.EOF
+		}
+	}
+	if s.err != nil {
+		s.Cancel()
+		return empty, s.err
+	}
+
+	resp := newMessage[Resp]()
+	if err := unmarshalReturnValue(rv, resp); err != nil {
+		return empty, fmt.Errorf("failed to unmarshal streamed message: %w", err)
+	}
+	return resp, nil
+}
+
+// Cancel abandons the stream. Messages that arrive later are discarded.
+func (s *Stream[Resp]) Cancel() {
+	s.cancel()
+	s.conn.forget(s.requestID)
+}
+
+// CallDurable sends a durable call of method over the MQ channel and returns immediately.
+// The future completes with the job ID once the receiving node persisted the call. The node then runs
+// the call until it succeeds, and moves it to its dead-letter queue after too many failed attempts.
+// The call's response is discarded.
+func CallDurable[Req proto.Message](ctx context.Context, service MQConnector, method string, req Req) *Future[string] {
+	return startAsync(ctx, func(ctx context.Context) (string, error) {
+		parameters, err := newCallParameters(ctx, method, req)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal call parameters: %w", err)
+		}
+		parameters.Durable = true
+		if _, err := sendCall(ctx, service, parameters); err != nil {
+			return "", err
+		}
+		return parameters.RequestId, nil
+	})
+}
+
+// CallOneWay sends a call of method over the MQ channel without waiting for, or getting, a reply.
+// It returns once the call is queued for sending, so it can't tell whether the call ran or failed.
+// ctx's deadline and outgoing metadata are sent along with the call.
+func CallOneWay[Req proto.Message](ctx context.Context, service MQConnector, method string, req Req) error {
+	conn, err := service.ConnectMQ()
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQ: %w", err)
+	}
+	parameters, err := newCallParameters(ctx, method, req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+	parameters.CallType = CallType_ONE_WAY
+	return conn.post(parameters)
+}
+
+// CallReplyTo sends a call of method over the MQ channel whose reply is pushed to replyTo instead of the caller,
+// e.g. to a worker collecting the results of many calls. replyTo is the address of a ZMQ PULL socket; other
+// transports reply to the caller, which discards the reply. It returns the call's request ID, which the reply
+// carries, see ReplyToResult. ctx's deadline and outgoing metadata are sent along with the call.
+func CallReplyTo[Req proto.Message](ctx context.Context, service MQConnector, method string, req Req, replyTo string) (string, error) {
+	conn, err := service.ConnectMQ()
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to MQ: %w", err)
+	}
+	parameters, err := newCallParameters(ctx, method, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+	parameters.ReplyTo = replyTo
+	if err := conn.post(parameters); err != nil {
+		return "", err
+	}
+	return parameters.RequestId, nil
+}
+
+// ReplyToResult decodes a reply pushed to the address of CallReplyTo, and returns the request ID of its call.
+// Errors reported by the service are returned as *CallError.
+func ReplyToResult[Resp proto.Message](data []byte) (string, Resp, error) {
+	var empty Resp
+	var rv ReturnValue
+	if err := proto.Unmarshal(data, &rv); err != nil {
+		return "", empty, fmt.Errorf("failed to unmarshal reply: %w", err)
+	}
+	resp, err := BatchResult[Resp](&rv)
+	return rv.RequestId, resp, err
+}
+
+func startAsync[T any](ctx context.Context, call func(ctx context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	f := &Future[T]{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(f.done)
+		defer cancel()
+		f.resp, f.err = call(ctx)
+	}()
+	return f
+}
+
+// callMQ sends a call over the MQ connection of service and waits for its successful ReturnValue.
+func callMQ(ctx context.Context, service MQConnector, method string, req proto.Message) (*ReturnValue, error) {
+	parameters, err := newCallParameters(ctx, method, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+	return sendCall(ctx, service, parameters)
+}
+
+// sendCall sends prepared call parameters and waits for their successful ReturnValue.
+func sendCall(ctx context.Context, service MQConnector, parameters *CallParameters) (*ReturnValue, error) {
+	conn, err := service.ConnectMQ()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQ: %w", err)
+	}
+	replies, err := conn.send(parameters, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, ok, err := replies.pop(ctx)
+	if err != nil {
+		conn.forget(parameters.RequestId)
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("MQ connection closed before the response arrived")
+	}
+
+	if err := errorFromReturnValue(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// priorityKey is the context key of the priority set by WithPriority
+type priorityKey struct{}
+
+// WithPriority returns a context whose MQ calls run in the given priority class of the server.
+// Calls default to Priority_PRIORITY_NORMAL.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// contentTypeKey is the context key of the content type set by WithContentType
+type contentTypeKey struct{}
+
+// WithContentType returns a context whose MQ calls encode their request and response as contentType,
+// e.g. ContentTypeJSON. Calls default to protobuf.
+func WithContentType(ctx context.Context, contentType string) context.Context {
+	return context.WithValue(ctx, contentTypeKey{}, contentType)
+}
+
+// newCallParameters builds the parameters of a call to method. The call gets a fresh request ID,
+// ctx's deadline, priority and content type, and the outgoing gRPC metadata of ctx
+// (see metadata.AppendToOutgoingContext).
+func newCallParameters(ctx context.Context, method string, req proto.Message) (*CallParameters, error) {
+	contentType, _ := ctx.Value(contentTypeKey{}).(string)
+	codec, err := CodecFor(contentType)
+	if err != nil {
+		return nil, err
+	}
+	data, err := codec.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	parameters := &CallParameters{
+		Method:      method,
+		Data:        data,
+		RequestId:   newRequestID(),
+		ContentType: contentType,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		parameters.Deadline = deadline.UnixMilli()
+	}
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		parameters.Priority = priority
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		parameters.Metadata = make(map[string]string, len(md))
+		for key, values := range md {
+			parameters.Metadata[key] = strings.Join(values, ",")
+		}
+	}
+	return parameters, nil
+}
+
+// newMessage allocates an empty message of a generated message type, e.g. *wrapperspb.StringValue.
+func newMessage[M proto.Message]() M {
+	var m M
+	return m.ProtoReflect().Type().New().Interface().(M)
+}
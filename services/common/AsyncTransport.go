@@ -0,0 +1,118 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// AsyncTransport carries MQ calls between clients and the MQ servers of services. Calls travel as
+// marshaled CallParameters and replies as marshaled ReturnValues, so a transport only moves bytes:
+// request IDs, deadlines, priorities and streams work the same over every transport.
+// A service selects its transport with MQConfig.Transport, and clients pick the transport of
+// the addresses they discover by their scheme.
+type AsyncTransport interface {
+	// Listen starts accepting calls on listenPort, or on a random port if 0, and returns the address
+	// clients dial. CurveZMQ is only supported by the zmq transport.
+	Listen(listenPort int, curve CurveConfig) (listener AsyncListener, listeningAddress string, err error)
+	// Dial connects to the nodes returned by discover, and calls discover again every mqRefreshInterval
+	// to follow nodes joining and leaving. Replies are passed to deliver.
+	Dial(discover func() ([]string, error), curve *CurveClientKeys, deliver func(data []byte)) (AsyncConn, error)
+}
+
+// AsyncListener receives the calls of an MQ server.
+type AsyncListener interface {
+	// Serve passes every received call to handle, with the function replying to its caller,
+	// until the listener fails. handle must not block.
+	Serve(handle func(data []byte, reply AsyncReply))
+}
+
+// AsyncReply sends a reply to the caller of a call. If replyTo isn't empty, the reply goes to that
+// address instead, on transports that support it. It may be called from any goroutine.
+type AsyncReply func(replyTo string, rv *ReturnValue)
+
+// AsyncConn is a client's connection to the nodes of a service.
+type AsyncConn interface {
+	// Send sends a call to one of the nodes. It may be called from any goroutine.
+	Send(data []byte) error
+	Close()
+}
+
+type asyncTransportEntry struct {
+	scheme    string
+	transport AsyncTransport
+}
+
+var (
+	asyncTransportsMutex sync.Mutex
+	asyncTransports      = make(map[string]asyncTransportEntry)
+)
+
+// the transport of services that don't configure one
+const defaultAsyncTransport = "zmq"
+
+// registerAsyncTransport makes a transport available under a name. scheme is the scheme of its addresses.
+func registerAsyncTransport(name string, scheme string, transport AsyncTransport) {
+	asyncTransportsMutex.Lock()
+	defer asyncTransportsMutex.Unlock()
+	asyncTransports[name] = asyncTransportEntry{scheme: scheme, transport: transport}
+}
+
+// asyncTransportByName returns the transport a service configures.
+func asyncTransportByName(name string) (AsyncTransport, error) {
+	if name == "" {
+		name = defaultAsyncTransport
+	}
+	asyncTransportsMutex.Lock()
+	defer asyncTransportsMutex.Unlock()
+	entry, ok := asyncTransports[name]
+	if !ok {
+		return nil, fmt.Errorf("async transport %v isn't available in this build", name)
+	}
+	return entry.transport, nil
+}
+
+// asyncTransportByAddress returns the transport serving an address, and the scheme of its addresses.
+func asyncTransportByAddress(address string) (AsyncTransport, string, error) {
+	scheme, _, ok := strings.Cut(address, "://")
+	if !ok {
+		return nil, "", fmt.Errorf("MQ address %v has no scheme", address)
+	}
+	asyncTransportsMutex.Lock()
+	defer asyncTransportsMutex.Unlock()
+	for _, entry := range asyncTransports {
+		if entry.scheme == scheme {
+			return entry.transport, scheme, nil
+		}
+	}
+	return nil, "", fmt.Errorf("no async transport for %v addresses in this build", scheme)
+}
+
+// dialAsync connects to the nodes returned by discover with the transport of their addresses.
+// Nodes of a service using another transport are skipped.
+func dialAsync(discover func() ([]string, error), curve *CurveClientKeys, deliver func(data []byte)) (AsyncConn, error) {
+	nodes, err := discover()
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no MQ nodes available")
+	}
+	transport, scheme, err := asyncTransportByAddress(nodes[0])
+	if err != nil {
+		return nil, err
+	}
+	return transport.Dial(func() ([]string, error) {
+		nodes, err := discover()
+		if err != nil {
+			return nil, err
+		}
+		var matching []string
+		for _, node := range nodes {
+			if strings.HasPrefix(node, scheme+"://") {
+				matching = append(matching, node)
+			}
+		}
+		return matching, nil
+	}, curve, deliver)
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// connectedService is an MQConnector with a connection made by the test
+type connectedService struct {
+	conn *MQConnection
+}
+
+func (s connectedService) ConnectMQ() (*MQConnection, error) {
+	return s.conn, nil
+}
+
+func TestAsyncTransports(t *testing.T) {
+	for _, transport := range []string{"inproc", "grpc"} {
+		t.Run(transport, func(t *testing.T) {
+			testAsyncTransport(t, transport)
+		})
+	}
+}
+
+func testAsyncTransport(t *testing.T, transport string) {
+	startMQ, address := BindMQToService(0, MQConfig{Transport: transport}, NewMQDispatcher(&pb.TestService_ServiceDesc, dispatcherTestServer{}))
+	go startMQ()
+	conn, err := NewMQConnection(func() ([]string, error) { return []string{address}, nil }, nil)
+	if err != nil {
+		t.Fatalf("NewMQConnection failed: %v", err)
+	}
+	defer conn.Close()
+	service := connectedService{conn}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := CallAsync[*wrapperspb.StringValue, *wrapperspb.StringValue](ctx, service, "HelloToUser", wrapperspb.String("Bob")).Get()
+	if err != nil || res.Value != "Hello Bob" {
+		t.Errorf("HelloToUser returned %v, %v; want Hello Bob", res, err)
+	}
+
+	_, err = CallAsync[*wrapperspb.StringValue, *wrapperspb.StringValue](ctx, service, "NoSuchMethod", wrapperspb.String("")).Get()
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Code != ErrorCode_METHOD_NOT_FOUND {
+		t.Errorf("expected METHOD_NOT_FOUND, got %v", err)
+	}
+
+	stream, err := CallStream[*wrapperspb.Int32Value, *wrapperspb.Int32Value](ctx, service, "WaitAndRand", wrapperspb.Int32(3))
+	if err != nil {
+		t.Fatalf("CallStream failed: %v", err)
+	}
+	for i := int32(0); ; i++ {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			if i != 3 {
+				t.Errorf("stream ended after %v messages; want 3", i)
+			}
+			break
+		}
+		if err != nil || msg.Value != i {
+			t.Fatalf("message %v: got %v, %v", i, msg, err)
+		}
+	}
+}
+
+func TestAsyncTransportByAddress(t *testing.T) {
+	for address, want := range map[string]string{"chan://1": "chan", "grpc://127.0.0.1:5000": "grpc"} {
+		if _, scheme, err := asyncTransportByAddress(address); err != nil || scheme != want {
+			t.Errorf("asyncTransportByAddress(%v) = %v, %v; want %v", address, scheme, err, want)
+		}
+	}
+	if _, _, err := asyncTransportByAddress("127.0.0.1:5000"); err == nil {
+		t.Errorf("expected an error for an address without a scheme")
+	}
+	if _, err := asyncTransportByName("carrier-pigeon"); err == nil {
+		t.Errorf("expected an error for an unknown transport")
+	}
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// how many calls of a batch a worker runs at once
+const mqBatchConcurrency = 16
+
+// Batch collects calls to send to a node in a single MQ message, see CallBatch.
+type Batch struct {
+	// run the calls one after the other, in order, instead of concurrently
+	Ordered bool
+
+	calls []*CallParameters
+}
+
+// Add adds a call of method to the batch and returns its index in the batch's replies.
+// ctx's outgoing metadata and content type are sent along with the call.
+func (b *Batch) Add(ctx context.Context, method string, req proto.Message) (int, error) {
+	parameters, err := newCallParameters(ctx, method, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+	// the calls run within the deadline of the batch
+	parameters.Deadline = 0
+	b.calls = append(b.calls, parameters)
+	return len(b.calls) - 1, nil
+}
+
+// Len returns the number of calls in the batch.
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// CallBatch sends the calls of a batch over the MQ channel in a single message, and returns immediately.
+// The future completes with the replies to the calls, in the order they were added, once all of them ran.
+// Decode them with BatchResult. ctx's deadline applies to the batch as a whole.
+//
+//	var batch Batch
+//	for _, key := range keys {
+//		batch.Add(ctx, "Get", wrapperspb.String(key))
+//	}
+//	replies, err := CallBatch(ctx, client, &batch).Get()
+//	value, err := BatchResult[*wrapperspb.StringValue](replies[0])
+func CallBatch(ctx context.Context, service MQConnector, batch *Batch) *Future[[]*ReturnValue] {
+	calls := batch.calls
+	ordered := batch.Ordered
+	return startAsync(ctx, func(ctx context.Context) ([]*ReturnValue, error) {
+		parameters, err := newCallParameters(ctx, "", &BatchCallParameters{Calls: calls, Ordered: ordered})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal batch: %w", err)
+		}
+		parameters.CallType = CallType_BATCH
+		ret, err := sendCall(ctx, service, parameters)
+		if err != nil {
+			return nil, err
+		}
+		var replies BatchReturnValue
+		if err := unmarshalReturnValue(ret, &replies); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal batch reply: %w", err)
+		}
+		if len(replies.Values) != len(calls) {
+			return nil, fmt.Errorf("batch of %v calls got %v replies", len(calls), len(replies.Values))
+		}
+		return replies.Values, nil
+	})
+}
+
+// BatchResult decodes the reply to a call of a batch. Errors reported by the service are returned as *CallError.
+func BatchResult[Resp proto.Message](rv *ReturnValue) (Resp, error) {
+	var empty Resp
+	if err := errorFromReturnValue(rv); err != nil {
+		return empty, err
+	}
+	resp := newMessage[Resp]()
+	if err := unmarshalReturnValue(rv, resp); err != nil {
+		return empty, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return resp, nil
+}
+
+// handleMQBatch runs the calls of a batch and replies with all their ReturnValues at once.
+// A call without a deadline gets the batch's. Streaming methods can't be called in a batch.
+func handleMQBatch(parameters *CallParameters, messageHandler MessageHandler) *ReturnValue {
+	codec, err := CodecFor(parameters.ContentType)
+	if err != nil {
+		return newErrorReturnValue(NewCallError(ErrorCode_UNMARSHAL_ERROR, "%v", err))
+	}
+	var batch BatchCallParameters
+	if err := codec.Unmarshal(parameters.Data, &batch); err != nil {
+		return newErrorReturnValue(NewCallError(ErrorCode_UNMARSHAL_ERROR, "failed to unmarshal batch: %v", err))
+	}
+
+	values := make([]*ReturnValue, len(batch.Calls))
+	run := func(i int) {
+		call := batch.Calls[i]
+		if call.Deadline == 0 {
+			call.Deadline = parameters.Deadline
+		}
+		rv := handleMQRequest(call, messageHandler, nil)
+		rv.RequestId = call.RequestId
+		values[i] = rv
+	}
+	if batch.Ordered {
+		for i := range batch.Calls {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		running := make(chan struct{}, mqBatchConcurrency)
+		for i := range batch.Calls {
+			wg.Add(1)
+			running <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-running }()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	data, err := codec.Marshal(&BatchReturnValue{Values: values})
+	if err != nil {
+		return newErrorReturnValue(NewCallError(ErrorCode_INTERNAL_ERROR, "failed to marshal batch reply: %v", err))
+	}
+	return &ReturnValue{Data: data, ContentType: parameters.ContentType}
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCallBatch(t *testing.T) {
+	startMQ, address := BindMQToService(0, MQConfig{Transport: "inproc"}, NewMQDispatcher(&pb.TestService_ServiceDesc, dispatcherTestServer{}))
+	go startMQ()
+	conn, err := NewMQConnection(func() ([]string, error) { return []string{address}, nil }, nil)
+	if err != nil {
+		t.Fatalf("NewMQConnection failed: %v", err)
+	}
+	defer conn.Close()
+	service := connectedService{conn}
+
+	for _, ordered := range []bool{false, true} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		batch := Batch{Ordered: ordered}
+		for i := 0; i < 50; i++ {
+			batch.Add(ctx, "HelloToUser", wrapperspb.String(fmt.Sprint(i)))
+		}
+		missing, _ := batch.Add(ctx, "Missing", wrapperspb.String(""))
+		jsonCall, _ := batch.Add(WithContentType(ctx, ContentTypeJSON), "HelloToUser", wrapperspb.String("JSON"))
+
+		replies, err := CallBatch(ctx, service, &batch).Get()
+		cancel()
+		if err != nil {
+			t.Fatalf("ordered %v: CallBatch failed: %v", ordered, err)
+		}
+		for i := 0; i < 50; i++ {
+			res, err := BatchResult[*wrapperspb.StringValue](replies[i])
+			if err != nil || res.Value != fmt.Sprint("Hello ", i) {
+				t.Errorf("ordered %v: call %v returned %v, %v", ordered, i, res, err)
+			}
+		}
+		var callErr *CallError
+		if _, err := BatchResult[*wrapperspb.StringValue](replies[missing]); !errors.As(err, &callErr) || callErr.Code != ErrorCode_METHOD_NOT_FOUND {
+			t.Errorf("ordered %v: expected METHOD_NOT_FOUND, got %v", ordered, err)
+		}
+		if res, err := BatchResult[*wrapperspb.StringValue](replies[jsonCall]); err != nil || res.Value != "Hello JSON" {
+			t.Errorf("ordered %v: JSON call returned %v, %v", ordered, res, err)
+		}
+	}
+}
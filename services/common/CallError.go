@@ -0,0 +1,43 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CallError is the error of an MQ call. The server sends its code and message
+// to the caller inside the ReturnValue, and the caller's future returns it.
+type CallError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func NewCallError(code ErrorCode, format string, args ...interface{}) *CallError {
+	return &CallError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Code, e.Message)
+}
+
+// newErrorReturnValue wraps err in a ReturnValue. Errors that aren't a CallError
+// come from the called method and are reported as SERVANT_ERROR.
+func newErrorReturnValue(err error) *ReturnValue {
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		callErr = &CallError{Code: ErrorCode_SERVANT_ERROR, Message: err.Error()}
+	}
+	return &ReturnValue{Code: callErr.Code, Error: callErr.Message}
+}
+
+// errorFromReturnValue returns the error carried by a ReturnValue, or nil if the call succeeded.
+func errorFromReturnValue(rv *ReturnValue) error {
+	if rv.Code == ErrorCode_OK && rv.Error == "" {
+		return nil
+	}
+	code := rv.Code
+	if code == ErrorCode_OK {
+		code = ErrorCode_SERVANT_ERROR
+	}
+	return &CallError{Code: code, Message: rv.Error}
+}
@@ -7,6 +7,7 @@
 package common
 
 import (
+	any1 "github.com/golang/protobuf/ptypes/any"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -20,15 +21,267 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// priority class of a call. Each class has its own workers and queue on the MQ server,
+// so cheap calls don't wait behind long ones
+type Priority int32
+
+const (
+	Priority_PRIORITY_NORMAL Priority = 0
+	// short calls that should be answered right away
+	Priority_PRIORITY_HIGH Priority = 1
+	// long-running or bulk calls
+	Priority_PRIORITY_LOW Priority = 2
+)
+
+// Enum value maps for Priority.
+var (
+	Priority_name = map[int32]string{
+		0: "PRIORITY_NORMAL",
+		1: "PRIORITY_HIGH",
+		2: "PRIORITY_LOW",
+	}
+	Priority_value = map[string]int32{
+		"PRIORITY_NORMAL": 0,
+		"PRIORITY_HIGH":   1,
+		"PRIORITY_LOW":    2,
+	}
+)
+
+func (x Priority) Enum() *Priority {
+	p := new(Priority)
+	*p = x
+	return p
+}
+
+func (x Priority) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Priority) Descriptor() protoreflect.EnumDescriptor {
+	return file_CallMessage_proto_enumTypes[0].Descriptor()
+}
+
+func (Priority) Type() protoreflect.EnumType {
+	return &file_CallMessage_proto_enumTypes[0]
+}
+
+func (x Priority) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Priority.Descriptor instead.
+func (Priority) EnumDescriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{0}
+}
+
+// how the server answers a call
+type CallType int32
+
+const (
+	// the server replies with a ReturnValue
+	CallType_REQUEST_REPLY CallType = 0
+	// the server runs the call and never replies, not even with an error
+	CallType_ONE_WAY CallType = 1
+	// data holds a BatchCallParameters, and the reply's data a BatchReturnValue. method is unused
+	CallType_BATCH CallType = 2
+)
+
+// Enum value maps for CallType.
+var (
+	CallType_name = map[int32]string{
+		0: "REQUEST_REPLY",
+		1: "ONE_WAY",
+		2: "BATCH",
+	}
+	CallType_value = map[string]int32{
+		"REQUEST_REPLY": 0,
+		"ONE_WAY":       1,
+		"BATCH":         2,
+	}
+)
+
+func (x CallType) Enum() *CallType {
+	p := new(CallType)
+	*p = x
+	return p
+}
+
+func (x CallType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CallType) Descriptor() protoreflect.EnumDescriptor {
+	return file_CallMessage_proto_enumTypes[1].Descriptor()
+}
+
+func (CallType) Type() protoreflect.EnumType {
+	return &file_CallMessage_proto_enumTypes[1]
+}
+
+func (x CallType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CallType.Descriptor instead.
+func (CallType) EnumDescriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{1}
+}
+
+// category of an error returned by an MQ call
+type ErrorCode int32
+
+const (
+	ErrorCode_OK ErrorCode = 0
+	// the service has no method with the requested name or signature
+	ErrorCode_METHOD_NOT_FOUND ErrorCode = 1
+	// the call parameters could not be unmarshaled
+	ErrorCode_UNMARSHAL_ERROR ErrorCode = 2
+	// the called method returned an error
+	ErrorCode_SERVANT_ERROR ErrorCode = 3
+	// the MQ server failed to process the call
+	ErrorCode_INTERNAL_ERROR ErrorCode = 4
+	// the call's deadline passed before it was processed
+	ErrorCode_DEADLINE_EXCEEDED ErrorCode = 5
+	// the queue of the call's priority class was full, so the call wasn't run
+	ErrorCode_OVERLOADED ErrorCode = 6
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0: "OK",
+		1: "METHOD_NOT_FOUND",
+		2: "UNMARSHAL_ERROR",
+		3: "SERVANT_ERROR",
+		4: "INTERNAL_ERROR",
+		5: "DEADLINE_EXCEEDED",
+		6: "OVERLOADED",
+	}
+	ErrorCode_value = map[string]int32{
+		"OK":                0,
+		"METHOD_NOT_FOUND":  1,
+		"UNMARSHAL_ERROR":   2,
+		"SERVANT_ERROR":     3,
+		"INTERNAL_ERROR":    4,
+		"DEADLINE_EXCEEDED": 5,
+		"OVERLOADED":        6,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_CallMessage_proto_enumTypes[2].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_CallMessage_proto_enumTypes[2]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{2}
+}
+
+// state change of a durable job, appended to the job log
+type JobEvent int32
+
+const (
+	// the job was persisted. Carries the call
+	JobEvent_JOB_ENQUEUED JobEvent = 0
+	// an attempt to run the job failed
+	JobEvent_JOB_FAILED JobEvent = 1
+	// the job ran successfully
+	JobEvent_JOB_COMPLETED JobEvent = 2
+	// the job failed too many times and was moved to the dead-letter queue
+	JobEvent_JOB_DEAD JobEvent = 3
+	// the job was moved back from the dead-letter queue to be retried
+	JobEvent_JOB_REPLAYED JobEvent = 4
+)
+
+// Enum value maps for JobEvent.
+var (
+	JobEvent_name = map[int32]string{
+		0: "JOB_ENQUEUED",
+		1: "JOB_FAILED",
+		2: "JOB_COMPLETED",
+		3: "JOB_DEAD",
+		4: "JOB_REPLAYED",
+	}
+	JobEvent_value = map[string]int32{
+		"JOB_ENQUEUED":  0,
+		"JOB_FAILED":    1,
+		"JOB_COMPLETED": 2,
+		"JOB_DEAD":      3,
+		"JOB_REPLAYED":  4,
+	}
+)
+
+func (x JobEvent) Enum() *JobEvent {
+	p := new(JobEvent)
+	*p = x
+	return p
+}
+
+func (x JobEvent) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (JobEvent) Descriptor() protoreflect.EnumDescriptor {
+	return file_CallMessage_proto_enumTypes[3].Descriptor()
+}
+
+func (JobEvent) Type() protoreflect.EnumType {
+	return &file_CallMessage_proto_enumTypes[3]
+}
+
+func (x JobEvent) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use JobEvent.Descriptor instead.
+func (JobEvent) EnumDescriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{3}
+}
+
 // method - name of method that should be called
 // data - serialized protobuf message
+// request_id - correlation ID of the call, copied to its ReturnValue
+// deadline - unix time in milliseconds after which the call is dropped. 0 for no deadline
+// reply_to - address of a PULL socket the reply is pushed to. Empty to reply to the calling socket
+// metadata - request metadata, e.g. auth tokens and trace context
+// durable - persist the call as a job that is retried until it succeeds, and reply once it is persisted
+// call_type - whether the caller waits for a reply
+// priority - the MQ server's worker pool the call runs on
+// content_type - encoding of data and of the reply's data: "application/x-protobuf" (the default if empty)
+// or "application/json" (protojson), for clients without generated Go code
 type CallParameters struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Method string `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
-	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Method      string            `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Data        []byte            `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	RequestId   string            `protobuf:"bytes,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Deadline    int64             `protobuf:"varint,4,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	ReplyTo     string            `protobuf:"bytes,5,opt,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	Metadata    map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Durable     bool              `protobuf:"varint,7,opt,name=durable,proto3" json:"durable,omitempty"`
+	CallType    CallType          `protobuf:"varint,8,opt,name=call_type,json=callType,proto3,enum=common.CallType" json:"call_type,omitempty"`
+	Priority    Priority          `protobuf:"varint,9,opt,name=priority,proto3,enum=common.Priority" json:"priority,omitempty"`
+	ContentType string            `protobuf:"bytes,10,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
 }
 
 func (x *CallParameters) Reset() {
@@ -77,15 +330,80 @@ func (x *CallParameters) GetData() []byte {
 	return nil
 }
 
+func (x *CallParameters) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *CallParameters) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
+func (x *CallParameters) GetReplyTo() string {
+	if x != nil {
+		return x.ReplyTo
+	}
+	return ""
+}
+
+func (x *CallParameters) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CallParameters) GetDurable() bool {
+	if x != nil {
+		return x.Durable
+	}
+	return false
+}
+
+func (x *CallParameters) GetCallType() CallType {
+	if x != nil {
+		return x.CallType
+	}
+	return CallType_REQUEST_REPLY
+}
+
+func (x *CallParameters) GetPriority() Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return Priority_PRIORITY_NORMAL
+}
+
+func (x *CallParameters) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
 // data - serialized protobuf return values message
 // error - error message. Empty in case no error
+// code - error category. OK in case no error
+// request_id - correlation ID of the call this value returns from
+// end_of_stream - set on the last reply of a call. A streaming call gets a reply per streamed
+// message, followed by an empty reply marking the end of the stream, or an error
+// content_type - encoding of data, that of the call
 type ReturnValue struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Data  []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Data        []byte    `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Error       string    `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Code        ErrorCode `protobuf:"varint,3,opt,name=code,proto3,enum=common.ErrorCode" json:"code,omitempty"`
+	RequestId   string    `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	EndOfStream bool      `protobuf:"varint,5,opt,name=end_of_stream,json=endOfStream,proto3" json:"end_of_stream,omitempty"`
+	ContentType string    `protobuf:"bytes,6,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
 }
 
 func (x *ReturnValue) Reset() {
@@ -134,19 +452,443 @@ func (x *ReturnValue) GetError() string {
 	return ""
 }
 
+func (x *ReturnValue) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_OK
+}
+
+func (x *ReturnValue) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *ReturnValue) GetEndOfStream() bool {
+	if x != nil {
+		return x.EndOfStream
+	}
+	return false
+}
+
+func (x *ReturnValue) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+// calls sent to a node in a single message, e.g. to store many values in one round trip
+// calls - the calls of the batch. Their own call types are ignored: each call gets a reply in the batch reply
+// ordered - run the calls one after the other, in order, instead of concurrently
+type BatchCallParameters struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Calls   []*CallParameters `protobuf:"bytes,1,rep,name=calls,proto3" json:"calls,omitempty"`
+	Ordered bool              `protobuf:"varint,2,opt,name=ordered,proto3" json:"ordered,omitempty"`
+}
+
+func (x *BatchCallParameters) Reset() {
+	*x = BatchCallParameters{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_CallMessage_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchCallParameters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCallParameters) ProtoMessage() {}
+
+func (x *BatchCallParameters) ProtoReflect() protoreflect.Message {
+	mi := &file_CallMessage_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCallParameters.ProtoReflect.Descriptor instead.
+func (*BatchCallParameters) Descriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BatchCallParameters) GetCalls() []*CallParameters {
+	if x != nil {
+		return x.Calls
+	}
+	return nil
+}
+
+func (x *BatchCallParameters) GetOrdered() bool {
+	if x != nil {
+		return x.Ordered
+	}
+	return false
+}
+
+// the reply to a batch
+// values - the replies to the calls, in the order of the calls
+type BatchReturnValue struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Values []*ReturnValue `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+}
+
+func (x *BatchReturnValue) Reset() {
+	*x = BatchReturnValue{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_CallMessage_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchReturnValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchReturnValue) ProtoMessage() {}
+
+func (x *BatchReturnValue) ProtoReflect() protoreflect.Message {
+	mi := &file_CallMessage_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchReturnValue.ProtoReflect.Descriptor instead.
+func (*BatchReturnValue) Descriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchReturnValue) GetValues() []*ReturnValue {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+// job_id - ID of the job, the request ID of the call that created it
+// event - what happened to the job
+// call - the call the job runs. Set on JOB_ENQUEUED only
+// error - error of a failed attempt
+// time - unix time in milliseconds of the event
+type JobRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	JobId string          `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Event JobEvent        `protobuf:"varint,2,opt,name=event,proto3,enum=common.JobEvent" json:"event,omitempty"`
+	Call  *CallParameters `protobuf:"bytes,3,opt,name=call,proto3" json:"call,omitempty"`
+	Error string          `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Time  int64           `protobuf:"varint,5,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *JobRecord) Reset() {
+	*x = JobRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_CallMessage_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *JobRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRecord) ProtoMessage() {}
+
+func (x *JobRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_CallMessage_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRecord.ProtoReflect.Descriptor instead.
+func (*JobRecord) Descriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *JobRecord) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *JobRecord) GetEvent() JobEvent {
+	if x != nil {
+		return x.Event
+	}
+	return JobEvent_JOB_ENQUEUED
+}
+
+func (x *JobRecord) GetCall() *CallParameters {
+	if x != nil {
+		return x.Call
+	}
+	return nil
+}
+
+func (x *JobRecord) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *JobRecord) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+// an event published on a topic
+// topic - name of the topic, e.g. "cache.invalidate"
+// payload - the event, a protobuf message of any type
+// time - unix time in milliseconds the event was published at
+type Event struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic   string    `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Payload *any1.Any `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	Time    int64     `protobuf:"varint,3,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_CallMessage_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_CallMessage_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Event) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Event) GetPayload() *any1.Any {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+// a message of the gRPC async transport
+// data - a marshaled CallParameters from the client, or a marshaled ReturnValue from the server
+type AsyncFrame struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *AsyncFrame) Reset() {
+	*x = AsyncFrame{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_CallMessage_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AsyncFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AsyncFrame) ProtoMessage() {}
+
+func (x *AsyncFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_CallMessage_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AsyncFrame.ProtoReflect.Descriptor instead.
+func (*AsyncFrame) Descriptor() ([]byte, []int) {
+	return file_CallMessage_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AsyncFrame) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
 var File_CallMessage_proto protoreflect.FileDescriptor
 
 var file_CallMessage_proto_rawDesc = []byte{
 	0x0a, 0x11, 0x43, 0x61, 0x6c, 0x6c, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x12, 0x06, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x22, 0x3c, 0x0a, 0x0e, 0x43,
-	0x61, 0x6c, 0x6c, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x12, 0x16, 0x0a,
-	0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d,
-	0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x37, 0x0a, 0x0b, 0x52, 0x65, 0x74,
-	0x75, 0x72, 0x6e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05,
-	0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72,
-	0x6f, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6f, 0x74, 0x6f, 0x12, 0x06, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x1a, 0x19, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x61, 0x6e, 0x79,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xab, 0x03, 0x0a, 0x0e, 0x43, 0x61, 0x6c, 0x6c, 0x50,
+	0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65,
+	0x12, 0x19, 0x0a, 0x08, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x5f, 0x74, 0x6f, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f, 0x12, 0x40, 0x0a, 0x08, 0x6d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e,
+	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x43, 0x61, 0x6c, 0x6c, 0x50, 0x61, 0x72, 0x61, 0x6d,
+	0x65, 0x74, 0x65, 0x72, 0x73, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x18, 0x0a,
+	0x07, 0x64, 0x75, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x64, 0x75, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x2d, 0x0a, 0x09, 0x63, 0x61, 0x6c, 0x6c, 0x5f,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x6f, 0x6e, 0x2e, 0x43, 0x61, 0x6c, 0x6c, 0x54, 0x79, 0x70, 0x65, 0x52, 0x08, 0x63, 0x61,
+	0x6c, 0x6c, 0x54, 0x79, 0x70, 0x65, 0x12, 0x2c, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69,
+	0x74, 0x79, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f,
+	0x6e, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0xc4, 0x01, 0x0a, 0x0b, 0x52, 0x65, 0x74, 0x75, 0x72, 0x6e, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f,
+	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x25,
+	0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x63,
+	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52,
+	0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x49, 0x64, 0x12, 0x22, 0x0a, 0x0d, 0x65, 0x6e, 0x64, 0x5f, 0x6f, 0x66, 0x5f, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x65, 0x6e, 0x64,
+	0x4f, 0x66, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x22, 0x5d, 0x0a, 0x13, 0x42,
+	0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x6c, 0x6c, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65,
+	0x72, 0x73, 0x12, 0x2c, 0x0a, 0x05, 0x63, 0x61, 0x6c, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x16, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x43, 0x61, 0x6c, 0x6c, 0x50,
+	0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x52, 0x05, 0x63, 0x61, 0x6c, 0x6c, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x65, 0x64, 0x22, 0x3f, 0x0a, 0x10, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x74, 0x75, 0x72, 0x6e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x2b,
+	0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13,
+	0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x52, 0x65, 0x74, 0x75, 0x72, 0x6e, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0xa0, 0x01, 0x0a, 0x09,
+	0x4a, 0x6f, 0x62, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x15, 0x0a, 0x06, 0x6a, 0x6f, 0x62,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6a, 0x6f, 0x62, 0x49, 0x64,
+	0x12, 0x26, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x10, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x4a, 0x6f, 0x62, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x52, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x2a, 0x0a, 0x04, 0x63, 0x61, 0x6c, 0x6c,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e,
+	0x43, 0x61, 0x6c, 0x6c, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x52, 0x04,
+	0x63, 0x61, 0x6c, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x69,
+	0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x22, 0x61,
+	0x0a, 0x05, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x2e, 0x0a,
+	0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x41, 0x6e, 0x79, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x12, 0x0a,
+	0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x69, 0x6d,
+	0x65, 0x22, 0x20, 0x0a, 0x0a, 0x41, 0x73, 0x79, 0x6e, 0x63, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x2a, 0x44, 0x0a, 0x08, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12,
+	0x13, 0x0a, 0x0f, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x4f, 0x52, 0x4d,
+	0x41, 0x4c, 0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d, 0x50, 0x52, 0x49, 0x4f, 0x52, 0x49, 0x54, 0x59,
+	0x5f, 0x48, 0x49, 0x47, 0x48, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x50, 0x52, 0x49, 0x4f, 0x52,
+	0x49, 0x54, 0x59, 0x5f, 0x4c, 0x4f, 0x57, 0x10, 0x02, 0x2a, 0x35, 0x0a, 0x08, 0x43, 0x61, 0x6c,
+	0x6c, 0x54, 0x79, 0x70, 0x65, 0x12, 0x11, 0x0a, 0x0d, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54,
+	0x5f, 0x52, 0x45, 0x50, 0x4c, 0x59, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x4f, 0x4e, 0x45, 0x5f,
+	0x57, 0x41, 0x59, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x42, 0x41, 0x54, 0x43, 0x48, 0x10, 0x02,
+	0x2a, 0x8c, 0x01, 0x0a, 0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x06,
+	0x0a, 0x02, 0x4f, 0x4b, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x4d, 0x45, 0x54, 0x48, 0x4f, 0x44,
+	0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f,
+	0x55, 0x4e, 0x4d, 0x41, 0x52, 0x53, 0x48, 0x41, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10,
+	0x02, 0x12, 0x11, 0x0a, 0x0d, 0x53, 0x45, 0x52, 0x56, 0x41, 0x4e, 0x54, 0x5f, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x10, 0x03, 0x12, 0x12, 0x0a, 0x0e, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c,
+	0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x04, 0x12, 0x15, 0x0a, 0x11, 0x44, 0x45, 0x41, 0x44,
+	0x4c, 0x49, 0x4e, 0x45, 0x5f, 0x45, 0x58, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x05, 0x12,
+	0x0e, 0x0a, 0x0a, 0x4f, 0x56, 0x45, 0x52, 0x4c, 0x4f, 0x41, 0x44, 0x45, 0x44, 0x10, 0x06, 0x2a,
+	0x5f, 0x0a, 0x08, 0x4a, 0x6f, 0x62, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x0c, 0x4a,
+	0x4f, 0x42, 0x5f, 0x45, 0x4e, 0x51, 0x55, 0x45, 0x55, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0e, 0x0a,
+	0x0a, 0x4a, 0x4f, 0x42, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x01, 0x12, 0x11, 0x0a,
+	0x0d, 0x4a, 0x4f, 0x42, 0x5f, 0x43, 0x4f, 0x4d, 0x50, 0x4c, 0x45, 0x54, 0x45, 0x44, 0x10, 0x02,
+	0x12, 0x0c, 0x0a, 0x08, 0x4a, 0x4f, 0x42, 0x5f, 0x44, 0x45, 0x41, 0x44, 0x10, 0x03, 0x12, 0x10,
+	0x0a, 0x0c, 0x4a, 0x4f, 0x42, 0x5f, 0x52, 0x45, 0x50, 0x4c, 0x41, 0x59, 0x45, 0x44, 0x10, 0x04,
+	0x32, 0x44, 0x0a, 0x0a, 0x41, 0x73, 0x79, 0x6e, 0x63, 0x43, 0x61, 0x6c, 0x6c, 0x73, 0x12, 0x36,
+	0x0a, 0x08, 0x45, 0x78, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x12, 0x2e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x6f, 0x6e, 0x2e, 0x41, 0x73, 0x79, 0x6e, 0x63, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x1a, 0x12,
+	0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x41, 0x73, 0x79, 0x6e, 0x63, 0x46, 0x72, 0x61,
+	0x6d, 0x65, 0x28, 0x01, 0x30, 0x01, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -161,17 +903,40 @@ func file_CallMessage_proto_rawDescGZIP() []byte {
 	return file_CallMessage_proto_rawDescData
 }
 
-var file_CallMessage_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_CallMessage_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_CallMessage_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_CallMessage_proto_goTypes = []any{
-	(*CallParameters)(nil), // 0: common.CallParameters
-	(*ReturnValue)(nil),    // 1: common.ReturnValue
+	(Priority)(0),               // 0: common.Priority
+	(CallType)(0),               // 1: common.CallType
+	(ErrorCode)(0),              // 2: common.ErrorCode
+	(JobEvent)(0),               // 3: common.JobEvent
+	(*CallParameters)(nil),      // 4: common.CallParameters
+	(*ReturnValue)(nil),         // 5: common.ReturnValue
+	(*BatchCallParameters)(nil), // 6: common.BatchCallParameters
+	(*BatchReturnValue)(nil),    // 7: common.BatchReturnValue
+	(*JobRecord)(nil),           // 8: common.JobRecord
+	(*Event)(nil),               // 9: common.Event
+	(*AsyncFrame)(nil),          // 10: common.AsyncFrame
+	nil,                         // 11: common.CallParameters.MetadataEntry
+	(*any1.Any)(nil),            // 12: google.protobuf.Any
 }
 var file_CallMessage_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	11, // 0: common.CallParameters.metadata:type_name -> common.CallParameters.MetadataEntry
+	1,  // 1: common.CallParameters.call_type:type_name -> common.CallType
+	0,  // 2: common.CallParameters.priority:type_name -> common.Priority
+	2,  // 3: common.ReturnValue.code:type_name -> common.ErrorCode
+	4,  // 4: common.BatchCallParameters.calls:type_name -> common.CallParameters
+	5,  // 5: common.BatchReturnValue.values:type_name -> common.ReturnValue
+	3,  // 6: common.JobRecord.event:type_name -> common.JobEvent
+	4,  // 7: common.JobRecord.call:type_name -> common.CallParameters
+	12, // 8: common.Event.payload:type_name -> google.protobuf.Any
+	10, // 9: common.AsyncCalls.Exchange:input_type -> common.AsyncFrame
+	10, // 10: common.AsyncCalls.Exchange:output_type -> common.AsyncFrame
+	10, // [10:11] is the sub-list for method output_type
+	9,  // [9:10] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_CallMessage_proto_init() }
@@ -204,19 +969,80 @@ func file_CallMessage_proto_init() {
 				return nil
 			}
 		}
+		file_CallMessage_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*BatchCallParameters); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_CallMessage_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*BatchReturnValue); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_CallMessage_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*JobRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_CallMessage_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*Event); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_CallMessage_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*AsyncFrame); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_CallMessage_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      4,
+			NumMessages:   8,
 			NumExtensions: 0,
-			NumServices:   0,
+			NumServices:   1,
 		},
 		GoTypes:           file_CallMessage_proto_goTypes,
 		DependencyIndexes: file_CallMessage_proto_depIdxs,
+		EnumInfos:         file_CallMessage_proto_enumTypes,
 		MessageInfos:      file_CallMessage_proto_msgTypes,
 	}.Build()
 	File_CallMessage_proto = out.File
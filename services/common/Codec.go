@@ -0,0 +1,74 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// content types of the data of MQ calls, see CallParameters.content_type
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// Codec encodes the request and response messages carried in MQ calls and replies.
+type Codec interface {
+	Marshal(m proto.Message) ([]byte, error)
+	Unmarshal(data []byte, m proto.Message) error
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Marshal(m proto.Message) ([]byte, error) {
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, m proto.Message) error {
+	return proto.Unmarshal(data, m)
+}
+
+// jsonCodec uses the canonical protobuf JSON mapping, so field names are lowerCamelCase,
+// and ignores unknown fields, as binary protobuf does.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(m proto.Message) ([]byte, error) {
+	return protojson.Marshal(m)
+}
+
+func (jsonCodec) Unmarshal(data []byte, m proto.Message) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+}
+
+// CodecFor returns the codec of a content type. An empty content type is protobuf.
+func CodecFor(contentType string) (Codec, error) {
+	switch contentType {
+	case "", ContentTypeProtobuf:
+		return protobufCodec{}, nil
+	case ContentTypeJSON:
+		return jsonCodec{}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type: %v", contentType)
+}
+
+// codecFromContext returns the codec of the MQ call a MessageHandler runs. The content type
+// was checked before the handler was called.
+func codecFromContext(ctx context.Context) Codec {
+	if parameters, ok := CallParametersFromContext(ctx); ok {
+		if codec, err := CodecFor(parameters.ContentType); err == nil {
+			return codec
+		}
+	}
+	return protobufCodec{}
+}
+
+// unmarshalReturnValue decodes the data of a reply, encoded as its content type.
+func unmarshalReturnValue(rv *ReturnValue, m proto.Message) error {
+	codec, err := CodecFor(rv.ContentType)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(rv.Data, m)
+}
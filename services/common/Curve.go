@@ -0,0 +1,110 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// length of a Z85-encoded CurveZMQ key
+const curveKeyLength = 40
+
+// CurveConfig enables CurveZMQ on MQ sockets: calls are encrypted, and servers only accept
+// clients whose public key is authorized. A service uses the same keypair as a server and as a client.
+type CurveConfig struct {
+	Enabled            bool              `yaml:"enabled"`
+	KeyFile            string            `yaml:"keyFile"`            // the keypair of this side, see CurveKeys
+	AuthorizedKeysFile string            `yaml:"authorizedKeysFile"` // servers: the client public keys allowed to call, one per line
+	ServerKeyFiles     map[string]string `yaml:"serverKeyFiles"`     // clients: by service name, a key file holding the public key of the service's nodes
+}
+
+// CurveKeys is a CurveZMQ keypair, stored in key files as YAML. The key files of servers given to
+// clients only hold the public key. All the nodes of a service share a keypair.
+type CurveKeys struct {
+	PublicKey string `yaml:"publicKey"`
+	SecretKey string `yaml:"secretKey,omitempty"`
+}
+
+// CurveClientKeys are the keys a client needs to connect to the nodes of a service.
+type CurveClientKeys struct {
+	ServerPublicKey string
+	PublicKey       string
+	SecretKey       string
+}
+
+// Save writes the keys to a key file readable only by its owner.
+func (keys *CurveKeys) Save(path string) error {
+	data, err := yaml.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// LoadCurveKeys reads a key file. If secret is true, the file must hold the secret key too.
+func LoadCurveKeys(path string, secret bool) (*CurveKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	var keys CurveKeys
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse key file %v: %v", path, err)
+	}
+	if len(keys.PublicKey) != curveKeyLength {
+		return nil, fmt.Errorf("key file %v has no valid public key", path)
+	}
+	if secret && len(keys.SecretKey) != curveKeyLength {
+		return nil, fmt.Errorf("key file %v has no valid secret key", path)
+	}
+	return &keys, nil
+}
+
+// loadAuthorizedKeys reads a file of public keys, one per line. Empty lines and lines starting with # are skipped.
+func loadAuthorizedKeys(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authorized keys: %v", err)
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if len(key) != curveKeyLength {
+			return nil, fmt.Errorf("%v:%v: invalid public key", path, line)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read authorized keys: %v", err)
+	}
+	return keys, nil
+}
+
+// ClientKeys returns the keys to connect to the nodes of a service, or nil if CurveZMQ is disabled.
+func (config CurveConfig) ClientKeys(serviceName string) (*CurveClientKeys, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	serverKeyFile, ok := config.ServerKeyFiles[serviceName]
+	if !ok {
+		return nil, fmt.Errorf("no server key file configured for %v", serviceName)
+	}
+	server, err := LoadCurveKeys(serverKeyFile, false)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := LoadCurveKeys(config.KeyFile, true)
+	if err != nil {
+		return nil, err
+	}
+	return &CurveClientKeys{ServerPublicKey: server.PublicKey, PublicKey: keys.PublicKey, SecretKey: keys.SecretKey}, nil
+}
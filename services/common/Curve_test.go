@@ -0,0 +1,71 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Z85 keys from the CurveZMQ test vectors
+const (
+	testPublicKey = "Yne@$w-vo<fVvi]a<NY6T1ed:M$fCG*[IaLV{hID"
+	testSecretKey = "D:)Q[IlAW!ahhC2ac:9*A}h:p?([4%wOTJ%JR%cs"
+	testServerKey = "rq:rM>}U?@Lns47E1%kR.o@n%FcmmsL/@{H8]yf7"
+)
+
+func TestCurveClientKeys(t *testing.T) {
+	dir := t.TempDir()
+	keys := &CurveKeys{PublicKey: testPublicKey, SecretKey: testSecretKey}
+	if err := keys.Save(filepath.Join(dir, "client.key")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	server := &CurveKeys{PublicKey: testServerKey}
+	if err := server.Save(filepath.Join(dir, "server.pub")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	config := CurveConfig{
+		KeyFile:        filepath.Join(dir, "client.key"),
+		ServerKeyFiles: map[string]string{"TestService": filepath.Join(dir, "server.pub")},
+	}
+	if clientKeys, err := config.ClientKeys("TestService"); clientKeys != nil || err != nil {
+		t.Errorf("disabled CurveZMQ returned keys %v, %v", clientKeys, err)
+	}
+
+	config.Enabled = true
+	clientKeys, err := config.ClientKeys("TestService")
+	if err != nil {
+		t.Fatalf("ClientKeys failed: %v", err)
+	}
+	want := CurveClientKeys{ServerPublicKey: testServerKey, PublicKey: testPublicKey, SecretKey: testSecretKey}
+	if *clientKeys != want {
+		t.Errorf("ClientKeys = %v; want %v", *clientKeys, want)
+	}
+	if _, err := config.ClientKeys("CacheService"); err == nil {
+		t.Errorf("expected an error for a service without a server key file")
+	}
+	if _, err := LoadCurveKeys(filepath.Join(dir, "server.pub"), true); err == nil {
+		t.Errorf("expected an error loading a public key file as a keypair")
+	}
+}
+
+func TestLoadAuthorizedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	content := strings.Join([]string{"# TestService", testPublicKey, "", "  " + testServerKey + "  "}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write authorized keys: %v", err)
+	}
+	keys, err := loadAuthorizedKeys(path)
+	if err != nil {
+		t.Fatalf("loadAuthorizedKeys failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != testPublicKey || keys[1] != testServerKey {
+		t.Errorf("loadAuthorizedKeys = %v", keys)
+	}
+
+	os.WriteFile(path, []byte(testPublicKey+"\nnot-a-key\n"), 0644)
+	if _, err := loadAuthorizedKeys(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
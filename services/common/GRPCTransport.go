@@ -0,0 +1,241 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	registerAsyncTransport("grpc", "grpc", grpcTransport{})
+}
+
+// grpcTransport carries MQ calls over gRPC, for hosts without libzmq. Servers run an AsyncCalls server,
+// and clients open an Exchange stream to every node of a service and spread their calls over them.
+// Reply-to addresses aren't supported: replies always go back on the caller's stream.
+type grpcTransport struct{}
+
+type grpcListener struct {
+	UnimplementedAsyncCallsServer
+	listener net.Listener
+	server   *grpc.Server
+	handle   func(data []byte, reply AsyncReply)
+}
+
+// Listen starts listening on listenPort. The AsyncCalls server starts once the listener serves.
+func (grpcTransport) Listen(listenPort int, curve CurveConfig) (AsyncListener, string, error) {
+	if curve.Enabled {
+		return nil, "", fmt.Errorf("CurveZMQ requires the zmq transport")
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%v", listenPort))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to listen: %v", err)
+	}
+	l := &grpcListener{listener: lis, server: grpc.NewServer()}
+	RegisterAsyncCallsServer(l.server, l)
+	return l, "grpc://" + lis.Addr().String(), nil
+}
+
+func (l *grpcListener) Serve(handle func(data []byte, reply AsyncReply)) {
+	l.handle = handle
+	if err := l.server.Serve(l.listener); err != nil {
+		log.Printf("Failed to serve async calls: %v\n", err)
+	}
+}
+
+// Exchange receives the calls of a client. Replies may be sent by many workers at once,
+// and a gRPC stream can only be sent to by one goroutine at a time.
+func (l *grpcListener) Exchange(stream AsyncCalls_ExchangeServer) error {
+	var sendMutex sync.Mutex
+	reply := func(replyTo string, rv *ReturnValue) {
+		data, err := proto.Marshal(rv)
+		if err != nil {
+			log.Printf("Failed to marshal ReturnValue: %v\n", err)
+			return
+		}
+		sendMutex.Lock()
+		defer sendMutex.Unlock()
+		if err := stream.Send(&AsyncFrame{Data: data}); err != nil {
+			log.Printf("Failed to send response: %v\n", err)
+		}
+	}
+	for {
+		frame, err := stream.Recv()
+		if err != nil {
+			// the client closed the stream. Replies still running fail to send
+			return nil
+		}
+		if len(frame.Data) > 0 {
+			l.handle(frame.Data, reply)
+		}
+	}
+}
+
+// grpcNode is the stream to a node of the service.
+type grpcNode struct {
+	conn      *grpc.ClientConn
+	cancel    context.CancelFunc
+	sendMutex sync.Mutex
+	stream    AsyncCalls_ExchangeClient
+}
+
+type grpcConn struct {
+	mutex    sync.Mutex
+	nodes    map[string]*grpcNode
+	next     int
+	closed   bool
+	done     chan struct{}
+	discover func() ([]string, error)
+	deliver  func(data []byte)
+}
+
+// Dial opens a stream to every node returned by discover.
+func (grpcTransport) Dial(discover func() ([]string, error), curve *CurveClientKeys, deliver func(data []byte)) (AsyncConn, error) {
+	if curve != nil {
+		return nil, fmt.Errorf("CurveZMQ requires the zmq transport")
+	}
+	c := &grpcConn{nodes: make(map[string]*grpcNode), done: make(chan struct{}), discover: discover, deliver: deliver}
+	nodes, err := discover()
+	if err != nil {
+		return nil, err
+	}
+	c.connect(nodes)
+	go c.refresh()
+	return c, nil
+}
+
+// connect opens streams to new nodes and closes the streams to nodes that left.
+func (c *grpcConn) connect(nodes []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	current := make(map[string]bool, len(nodes))
+	for _, address := range nodes {
+		current[address] = true
+		if _, ok := c.nodes[address]; ok {
+			continue
+		}
+		node, err := dialGRPCNode(address)
+		if err != nil {
+			log.Printf("Failed to connect to node %v: %v\n", address, err)
+			continue
+		}
+		c.nodes[address] = node
+		go c.receive(address, node)
+		log.Printf("Connected to node: %s", address)
+	}
+	for address, node := range c.nodes {
+		if !current[address] {
+			node.close()
+			delete(c.nodes, address)
+		}
+	}
+}
+
+func dialGRPCNode(address string) (*grpcNode, error) {
+	conn, err := grpc.Dial(strings.TrimPrefix(address, "grpc://"), grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewAsyncCallsClient(conn).Exchange(ctx)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+	return &grpcNode{conn: conn, cancel: cancel, stream: stream}, nil
+}
+
+func (node *grpcNode) close() {
+	node.cancel()
+	node.conn.Close()
+}
+
+// receive delivers the replies of a node until its stream breaks. The node is then dropped,
+// and reconnected by the next refresh if it is still registered.
+func (c *grpcConn) receive(address string, node *grpcNode) {
+	for {
+		frame, err := node.stream.Recv()
+		if err != nil {
+			break
+		}
+		c.deliver(frame.Data)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.nodes[address] == node {
+		node.close()
+		delete(c.nodes, address)
+	}
+}
+
+func (c *grpcConn) refresh() {
+	ticker := time.NewTicker(mqRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		nodes, err := c.discover()
+		if err != nil {
+			log.Printf("Failed to refresh nodes: %v\n", err)
+			continue
+		}
+		c.connect(nodes)
+	}
+}
+
+// Send sends a call to the next node, round robin.
+func (c *grpcConn) Send(data []byte) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return fmt.Errorf("MQ connection is closed")
+	}
+	addresses := make([]string, 0, len(c.nodes))
+	for address := range c.nodes {
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		c.mutex.Unlock()
+		return fmt.Errorf("no MQ nodes connected")
+	}
+	sort.Strings(addresses)
+	c.next++
+	node := c.nodes[addresses[c.next%len(addresses)]]
+	c.mutex.Unlock()
+
+	node.sendMutex.Lock()
+	defer node.sendMutex.Unlock()
+	if err := node.stream.Send(&AsyncFrame{Data: data}); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+func (c *grpcConn) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.done)
+	for address, node := range c.nodes {
+		node.close()
+		delete(c.nodes, address)
+	}
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	registerAsyncTransport("inproc", "chan", inprocTransport{})
+}
+
+// calls a server of the inproc transport buffers before its clients block
+const inprocQueueLength = 1024
+
+var (
+	inprocMutex     sync.Mutex
+	inprocListeners = make(map[string]*inprocListener)
+	inprocCounter   atomic.Int64
+)
+
+// inprocTransport carries MQ calls over channels between the services and clients of a process,
+// so async calls can be tested without sockets. Replies are delivered to the caller directly.
+type inprocTransport struct{}
+
+type inprocCall struct {
+	data  []byte
+	reply AsyncReply
+}
+
+type inprocListener struct {
+	calls chan inprocCall
+}
+
+// Listen registers a server under the address chan://<listenPort>, or a new number if listenPort is 0.
+func (inprocTransport) Listen(listenPort int, curve CurveConfig) (AsyncListener, string, error) {
+	if curve.Enabled {
+		return nil, "", fmt.Errorf("CurveZMQ requires the zmq transport")
+	}
+	name := int64(listenPort)
+	if name == 0 {
+		name = inprocCounter.Add(1)
+	}
+	address := fmt.Sprintf("chan://%d", name)
+
+	inprocMutex.Lock()
+	defer inprocMutex.Unlock()
+	if _, ok := inprocListeners[address]; ok {
+		return nil, "", fmt.Errorf("address %v is already in use", address)
+	}
+	l := &inprocListener{calls: make(chan inprocCall, inprocQueueLength)}
+	inprocListeners[address] = l
+	return l, address, nil
+}
+
+func (l *inprocListener) Serve(handle func(data []byte, reply AsyncReply)) {
+	for call := range l.calls {
+		handle(call.data, call.reply)
+	}
+}
+
+type inprocConn struct {
+	mutex       sync.Mutex
+	nodes       []string
+	next        int
+	lastRefresh time.Time
+	closed      bool
+	discover    func() ([]string, error)
+	deliver     func(data []byte)
+}
+
+// Dial returns a connection sending calls to the servers returned by discover.
+func (inprocTransport) Dial(discover func() ([]string, error), curve *CurveClientKeys, deliver func(data []byte)) (AsyncConn, error) {
+	if curve != nil {
+		return nil, fmt.Errorf("CurveZMQ requires the zmq transport")
+	}
+	nodes, err := discover()
+	if err != nil {
+		return nil, err
+	}
+	return &inprocConn{nodes: nodes, lastRefresh: time.Now(), discover: discover, deliver: deliver}, nil
+}
+
+// Send hands a call to the next server, round robin. The nodes are looked up again
+// on the first call after mqRefreshInterval.
+func (c *inprocConn) Send(data []byte) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return fmt.Errorf("MQ connection is closed")
+	}
+	if time.Since(c.lastRefresh) >= mqRefreshInterval {
+		if nodes, err := c.discover(); err == nil {
+			c.nodes = nodes
+		} else {
+			log.Printf("Failed to refresh nodes: %v\n", err)
+		}
+		c.lastRefresh = time.Now()
+	}
+	if len(c.nodes) == 0 {
+		c.mutex.Unlock()
+		return fmt.Errorf("no MQ nodes available")
+	}
+	c.next++
+	address := c.nodes[c.next%len(c.nodes)]
+	c.mutex.Unlock()
+
+	inprocMutex.Lock()
+	l, ok := inprocListeners[address]
+	inprocMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no inproc MQ server at %v", address)
+	}
+	// the caller may reuse its buffer
+	call := inprocCall{data: append([]byte(nil), data...), reply: c.reply}
+	l.calls <- call
+	return nil
+}
+
+// reply delivers a reply to the connection. Reply-to addresses aren't supported.
+func (c *inprocConn) reply(replyTo string, rv *ReturnValue) {
+	data, err := proto.Marshal(rv)
+	if err != nil {
+		log.Printf("Failed to marshal ReturnValue: %v\n", err)
+		return
+	}
+	c.deliver(data)
+}
+
+func (c *inprocConn) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+}
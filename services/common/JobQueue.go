@@ -0,0 +1,453 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// JobQueueConfig configures the durable mode of async calls
+type JobQueueConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	LogPath           string `yaml:"logPath"` // may contain %d, see NewJobQueue
+	VisibilityTimeout int    `yaml:"visibilityTimeout"` // seconds an attempt may take before the job is redelivered
+	MaxAttempts       int    `yaml:"maxAttempts"`
+}
+
+const (
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultMaxAttempts       = 5
+	// wait between attempts, and between connection retries while no MQ node is available
+	jobRetryDelay = time.Second
+)
+
+// DeadLetter is a durable job that failed too many times.
+type DeadLetter struct {
+	JobID    string
+	Method   string
+	Attempts int
+	Error    string
+}
+
+type job struct {
+	call      *CallParameters
+	attempts  int
+	lastError string
+	dead      bool
+	running   bool
+}
+
+// JobQueue runs durable MQ calls. A durable call is appended to a local log before it is acknowledged,
+// then sent to the service's MQ nodes until an attempt succeeds. An attempt that doesn't complete within
+// the visibility timeout fails, and the next attempt goes to the next node. After MaxAttempts failures
+// the job moves to the dead-letter queue, from which it can be replayed.
+// Every visibility timeout, a queue claims the jobs of the log slots no live queue holds, see NewJobQueue,
+// so the jobs of a node that died are redelivered by another node.
+// Jobs run at least once: a node that dies after running a job but before replying gets it redelivered.
+type JobQueue struct {
+	mutex             sync.Mutex
+	log               *os.File
+	logPath           string
+	jobs              map[string]*job
+	service           MQConnector
+	visibilityTimeout time.Duration
+	maxAttempts       int
+	// closed when the queue is closed, to stop claiming jobs
+	closed chan struct{}
+}
+
+// NewJobQueue opens the job log at config.LogPath and recovers the jobs it holds.
+// The log is locked while the queue uses it. If LogPath contains %d, the queue takes the first
+// log slot (0, 1, ...) no other queue holds, so a node restarting after a crash recovers the jobs
+// of a node that died, and several nodes can run on the same machine. The other slots are claimed
+// by the running queues once their node dies, see claimOrphans.
+// Jobs are sent through service, usually a client of the service owning the queue.
+func NewJobQueue(config JobQueueConfig, service MQConnector) (*JobQueue, error) {
+	file, err := openJobLog(config.LogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job log: %v", err)
+	}
+	q := &JobQueue{
+		log:               file,
+		logPath:           config.LogPath,
+		jobs:              make(map[string]*job),
+		service:           service,
+		visibilityTimeout: time.Duration(config.VisibilityTimeout) * time.Second,
+		maxAttempts:       config.MaxAttempts,
+		closed:            make(chan struct{}),
+	}
+	if q.visibilityTimeout <= 0 {
+		q.visibilityTimeout = defaultVisibilityTimeout
+	}
+	if q.maxAttempts <= 0 {
+		q.maxAttempts = defaultMaxAttempts
+	}
+
+	if err := q.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func openJobLog(path string) (*os.File, error) {
+	if !strings.Contains(path, "%d") {
+		return lockJobLog(path)
+	}
+	for slot := 0; ; slot++ {
+		file, err := lockJobLog(fmt.Sprintf(path, slot))
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return file, err
+		}
+	}
+}
+
+func lockJobLog(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// replay applies the records of the log. A record torn by a crash while it was written is cut off.
+func (q *JobQueue) replay() error {
+	reader := bufio.NewReader(q.log)
+	var offset int64
+	for {
+		size, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return nil
+		}
+		data := make([]byte, size)
+		if err == nil {
+			_, err = io.ReadFull(reader, data)
+		}
+		var record JobRecord
+		if err == nil {
+			err = proto.Unmarshal(data, &record)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("Job log ends with a partial record, truncating it at %v\n", offset)
+			return q.log.Truncate(offset)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read job log: %v", err)
+		}
+		q.apply(&record)
+		offset += int64(binary.PutUvarint(make([]byte, binary.MaxVarintLen64), size)) + int64(size)
+	}
+}
+
+func (q *JobQueue) apply(record *JobRecord) {
+	if record.Event == JobEvent_JOB_ENQUEUED {
+		q.jobs[record.JobId] = &job{call: record.Call}
+		return
+	}
+	j, ok := q.jobs[record.JobId]
+	if !ok {
+		return
+	}
+	switch record.Event {
+	case JobEvent_JOB_FAILED:
+		j.attempts++
+		j.lastError = record.Error
+	case JobEvent_JOB_COMPLETED:
+		delete(q.jobs, record.JobId)
+	case JobEvent_JOB_DEAD:
+		j.dead = true
+	case JobEvent_JOB_REPLAYED:
+		j.dead = false
+		j.attempts = 0
+	}
+}
+
+// record appends a record to the log, and syncs it to disk, before applying it. Called with the mutex held.
+func (q *JobQueue) record(record *JobRecord) error {
+	record.Time = time.Now().UnixMilli()
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	frame := binary.AppendUvarint(nil, uint64(len(data)))
+	frame = append(frame, data...)
+	if _, err := q.log.Write(frame); err != nil {
+		return err
+	}
+	if err := q.log.Sync(); err != nil {
+		return err
+	}
+	q.apply(record)
+	return nil
+}
+
+// Start runs the jobs recovered from the log, and starts claiming the jobs of the nodes that died.
+func (q *JobQueue) Start() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for jobID, j := range q.jobs {
+		if !j.dead {
+			q.launch(jobID, j)
+		}
+	}
+	go q.claimLoop()
+}
+
+// claimLoop claims the jobs of the log slots no queue holds every visibility timeout, until the queue is closed.
+func (q *JobQueue) claimLoop() {
+	ticker := time.NewTicker(q.visibilityTimeout)
+	defer ticker.Stop()
+	for {
+		if claimed := q.claimOrphans(); claimed > 0 {
+			log.Printf("Claimed %v jobs of nodes that went away\n", claimed)
+		}
+		select {
+		case <-q.closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimOrphans moves the jobs of the log slots no queue holds a lock on to this queue, and returns
+// how many it moved. Only the slots of the queues sharing its log directory are claimed. A slot is only free once the node that held it died, since nodes hold their slot
+// while they run. Jobs are appended to this queue's log before the slot's log is emptied, so a crash
+// while moving them only makes them run twice.
+func (q *JobQueue) claimOrphans() int {
+	if !strings.Contains(q.logPath, "%d") {
+		return 0
+	}
+	paths, err := filepath.Glob(strings.Replace(q.logPath, "%d", "*", 1))
+	if err != nil {
+		log.Printf("Failed to list job log slots: %v\n", err)
+		return 0
+	}
+	claimed := 0
+	for _, path := range paths {
+		if path == q.log.Name() {
+			continue
+		}
+		file, err := lockJobLog(path)
+		if err != nil {
+			// the slot of a live queue
+			if !errors.Is(err, syscall.EWOULDBLOCK) {
+				log.Printf("Failed to open job log %v: %v\n", path, err)
+			}
+			continue
+		}
+		moved, err := q.claim(file)
+		if err != nil {
+			log.Printf("Failed to claim the jobs of %v: %v\n", path, err)
+		}
+		claimed += moved
+		file.Close()
+	}
+	return claimed
+}
+
+// claim moves the jobs of a locked log to this queue, with their attempts, and empties the log.
+func (q *JobQueue) claim(file *os.File) (int, error) {
+	orphan := &JobQueue{log: file, jobs: make(map[string]*job)}
+	if err := orphan.replay(); err != nil {
+		return 0, err
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	moved := 0
+	for jobID, j := range orphan.jobs {
+		if _, ok := q.jobs[jobID]; ok {
+			continue
+		}
+		records := []*JobRecord{{JobId: jobID, Event: JobEvent_JOB_ENQUEUED, Call: j.call}}
+		for i := 0; i < j.attempts; i++ {
+			records = append(records, &JobRecord{JobId: jobID, Event: JobEvent_JOB_FAILED, Error: j.lastError})
+		}
+		if j.dead {
+			records = append(records, &JobRecord{JobId: jobID, Event: JobEvent_JOB_DEAD})
+		}
+		for _, record := range records {
+			if err := q.record(record); err != nil {
+				return moved, err
+			}
+		}
+		if !j.dead {
+			q.launch(jobID, q.jobs[jobID])
+		}
+		moved++
+	}
+	return moved, file.Truncate(0)
+}
+
+// launch starts running a job unless it already runs. Called with the mutex held.
+func (q *JobQueue) launch(jobID string, j *job) {
+	if j.running {
+		return
+	}
+	j.running = true
+	go q.run(jobID)
+}
+
+// Handler wraps the MessageHandler of a service: durable calls are persisted and
+// acknowledged with their job ID, and all other calls are passed to next.
+func (q *JobQueue) Handler(next MessageHandler) MessageHandler {
+	return func(ctx context.Context, method string, parameters []byte) (proto.Message, error) {
+		call, ok := CallParametersFromContext(ctx)
+		if !ok || !call.Durable {
+			return next(ctx, method, parameters)
+		}
+		if err := q.Enqueue(call); err != nil {
+			return nil, NewCallError(ErrorCode_INTERNAL_ERROR, "failed to persist job: %v", err)
+		}
+		return wrapperspb.String(call.RequestId), nil
+	}
+}
+
+// Enqueue persists a durable call as a job, identified by the call's request ID, and starts running it.
+// Enqueuing a job that is already queued does nothing, so callers may retry.
+func (q *JobQueue) Enqueue(call *CallParameters) error {
+	call = proto.Clone(call).(*CallParameters)
+	// the job's attempts are plain calls replying to the queue
+	call.Durable = false
+	call.Deadline = 0
+	call.ReplyTo = ""
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.jobs[call.RequestId]; ok {
+		return nil
+	}
+	err := q.record(&JobRecord{JobId: call.RequestId, Event: JobEvent_JOB_ENQUEUED, Call: call})
+	if err != nil {
+		return err
+	}
+	q.launch(call.RequestId, q.jobs[call.RequestId])
+	return nil
+}
+
+func (q *JobQueue) run(jobID string) {
+	for {
+		q.mutex.Lock()
+		j, ok := q.jobs[jobID]
+		if !ok || j.dead {
+			if ok {
+				j.running = false
+			}
+			q.mutex.Unlock()
+			return
+		}
+		call := proto.Clone(j.call).(*CallParameters)
+		q.mutex.Unlock()
+
+		// attempts can't run while no node is reachable, and don't count
+		if _, err := q.service.ConnectMQ(); err != nil {
+			time.Sleep(jobRetryDelay)
+			continue
+		}
+
+		err := q.attempt(call)
+		q.mutex.Lock()
+		if err == nil {
+			q.recordOrLog(&JobRecord{JobId: jobID, Event: JobEvent_JOB_COMPLETED})
+			q.mutex.Unlock()
+			return
+		}
+		log.Printf("Attempt of job %v (%v) failed: %v\n", jobID, call.Method, err)
+		q.recordOrLog(&JobRecord{JobId: jobID, Event: JobEvent_JOB_FAILED, Error: err.Error()})
+		if j.attempts >= q.maxAttempts || isPermanentCallError(err) {
+			log.Printf("Moving job %v (%v) to the dead-letter queue after %v attempts\n", jobID, call.Method, j.attempts)
+			q.recordOrLog(&JobRecord{JobId: jobID, Event: JobEvent_JOB_DEAD})
+		}
+		q.mutex.Unlock()
+		time.Sleep(jobRetryDelay)
+	}
+}
+
+// attempt sends one attempt of a job, which fails unless it completes within the visibility timeout.
+func (q *JobQueue) attempt(call *CallParameters) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.visibilityTimeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	call.RequestId = newRequestID()
+	call.Deadline = deadline.UnixMilli()
+	_, err := sendCall(ctx, q.service, call)
+	return err
+}
+
+// recordOrLog records an event that happened anyway: if the log can't be written, the job's
+// in-memory state still changes, and a restart may repeat the event.
+func (q *JobQueue) recordOrLog(record *JobRecord) {
+	if err := q.record(record); err != nil {
+		log.Printf("Failed to write job log: %v\n", err)
+		q.apply(record)
+	}
+}
+
+// isPermanentCallError reports whether retrying the call can't help.
+func isPermanentCallError(err error) bool {
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		return false
+	}
+	return callErr.Code == ErrorCode_METHOD_NOT_FOUND || callErr.Code == ErrorCode_UNMARSHAL_ERROR
+}
+
+// DeadLetters returns the jobs in the dead-letter queue, sorted by job ID.
+func (q *JobQueue) DeadLetters() []DeadLetter {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var deadLetters []DeadLetter
+	for jobID, j := range q.jobs {
+		if j.dead {
+			deadLetters = append(deadLetters, DeadLetter{JobID: jobID, Method: j.call.Method, Attempts: j.attempts, Error: j.lastError})
+		}
+	}
+	sort.Slice(deadLetters, func(i, k int) bool { return deadLetters[i].JobID < deadLetters[k].JobID })
+	return deadLetters
+}
+
+// Replay moves a job from the dead-letter queue back to the queue, with its attempts reset.
+func (q *JobQueue) Replay(jobID string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	j, ok := q.jobs[jobID]
+	if !ok || !j.dead {
+		return fmt.Errorf("no dead letter with job ID %v", jobID)
+	}
+	err := q.record(&JobRecord{JobId: jobID, Event: JobEvent_JOB_REPLAYED})
+	if err != nil {
+		return err
+	}
+	q.launch(jobID, j)
+	return nil
+}
+
+// Close closes the job log. Running jobs stay in the log and are recovered by the next queue opening it,
+// or claimed by another queue.
+func (q *JobQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	select {
+	case <-q.closed:
+	default:
+		close(q.closed)
+	}
+	return q.log.Close()
+}
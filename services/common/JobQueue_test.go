@@ -0,0 +1,140 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unreachableService makes jobs wait for a connection, so tests can inspect the queue
+type unreachableService struct{}
+
+func (unreachableService) ConnectMQ() (*MQConnection, error) {
+	return nil, fmt.Errorf("no MQ nodes available")
+}
+
+func TestJobQueueRecovery(t *testing.T) {
+	config := JobQueueConfig{LogPath: filepath.Join(t.TempDir(), "jobs.log")}
+	q, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	for _, jobID := range []string{"a", "b", "c"} {
+		if err := q.Enqueue(&CallParameters{Method: "HelloWorld", RequestId: jobID, Durable: true}); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+	q.mutex.Lock()
+	q.record(&JobRecord{JobId: "a", Event: JobEvent_JOB_COMPLETED})
+	q.record(&JobRecord{JobId: "b", Event: JobEvent_JOB_FAILED, Error: "servant failed"})
+	q.record(&JobRecord{JobId: "b", Event: JobEvent_JOB_DEAD})
+	q.mutex.Unlock()
+	q.Close()
+
+	// a crash while writing leaves a partial record behind
+	file, _ := os.OpenFile(config.LogPath, os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write([]byte{0x20, 0x01})
+	file.Close()
+
+	q, err = NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("reopening the job log failed: %v", err)
+	}
+	defer q.Close()
+	if len(q.jobs) != 2 || q.jobs["c"] == nil || q.jobs["c"].call.Durable {
+		t.Errorf("expected jobs b and c to be recovered, got %v", q.jobs)
+	}
+	deadLetters := q.DeadLetters()
+	if len(deadLetters) != 1 || deadLetters[0].JobID != "b" || deadLetters[0].Attempts != 1 {
+		t.Fatalf("DeadLetters() = %v; want job b after 1 attempt", deadLetters)
+	}
+	if err := q.Replay("b"); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(q.DeadLetters()) != 0 {
+		t.Errorf("job b is still a dead letter after Replay")
+	}
+	if err := q.Replay("c"); err == nil {
+		t.Errorf("expected Replay of a queued job to fail")
+	}
+}
+
+func TestJobQueueLogSlots(t *testing.T) {
+	config := JobQueueConfig{LogPath: filepath.Join(t.TempDir(), "jobs-%d.log")}
+	first, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	second, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	defer second.Close()
+	if first.log.Name() == second.log.Name() {
+		t.Errorf("both queues use %v", first.log.Name())
+	}
+
+	// a restarted node takes the slot of the node that went away
+	firstLog := first.log.Name()
+	first.Close()
+	third, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	defer third.Close()
+	if third.log.Name() != firstLog {
+		t.Errorf("restarted queue uses %v; want %v", third.log.Name(), firstLog)
+	}
+}
+
+func TestJobQueueClaim(t *testing.T) {
+	config := JobQueueConfig{LogPath: filepath.Join(t.TempDir(), "jobs-%d.log")}
+	live, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	defer live.Close()
+	dead, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	for _, jobID := range []string{"a", "b"} {
+		if err := dead.Enqueue(&CallParameters{Method: "HelloWorld", RequestId: jobID, Durable: true}); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+	dead.mutex.Lock()
+	dead.record(&JobRecord{JobId: "b", Event: JobEvent_JOB_FAILED, Error: "servant failed"})
+	dead.record(&JobRecord{JobId: "b", Event: JobEvent_JOB_DEAD})
+	dead.mutex.Unlock()
+
+	// the slot of a live queue isn't claimed
+	if claimed := live.claimOrphans(); claimed != 0 {
+		t.Errorf("claimOrphans() claimed %v jobs of a live queue", claimed)
+	}
+
+	// the node of the second queue dies
+	dead.Close()
+	if claimed := live.claimOrphans(); claimed != 2 {
+		t.Fatalf("claimOrphans() claimed %v jobs; want 2", claimed)
+	}
+	deadLetters := live.DeadLetters()
+	if len(live.jobs) != 2 || len(deadLetters) != 1 || deadLetters[0].JobID != "b" || deadLetters[0].Attempts != 1 {
+		t.Errorf("jobs = %v with dead letters %v; want a queued and b dead after 1 attempt", live.jobs, deadLetters)
+	}
+	if claimed := live.claimOrphans(); claimed != 0 {
+		t.Errorf("claimOrphans() claimed %v jobs again", claimed)
+	}
+
+	// the claimed jobs are in the log of the live queue
+	live.Close()
+	reopened, err := NewJobQueue(config, unreachableService{})
+	if err != nil {
+		t.Fatalf("NewJobQueue failed: %v", err)
+	}
+	defer reopened.Close()
+	if len(reopened.jobs) != 2 {
+		t.Errorf("reopened queue recovered %v; want jobs a and b", reopened.jobs)
+	}
+}
@@ -0,0 +1,190 @@
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// how often a connection looks up the MQ nodes of its service, to connect to new nodes
+const mqRefreshInterval = 5 * time.Second
+
+// MQConnection multiplexes the MQ calls of a client over a single AsyncConn.
+// Replies are matched to their calls by request ID, so any number of calls can be in flight at once.
+type MQConnection struct {
+	mutex   sync.Mutex
+	conn    AsyncConn
+	pending map[string]*replyQueue
+	closed  bool
+}
+
+// replyQueue holds the replies of a call until the caller takes them. It never blocks the goroutine
+// delivering replies, so a slow stream consumer doesn't hold up the other calls of the connection.
+type replyQueue struct {
+	mutex   sync.Mutex
+	stream  bool
+	replies []*ReturnValue
+	closed  bool
+	ready   chan struct{}
+}
+
+func newReplyQueue(stream bool) *replyQueue {
+	return &replyQueue{stream: stream, ready: make(chan struct{}, 1)}
+}
+
+func (q *replyQueue) push(rv *ReturnValue) {
+	q.mutex.Lock()
+	q.replies = append(q.replies, rv)
+	q.mutex.Unlock()
+	q.notify()
+}
+
+// close marks the queue as complete. Replies already queued can still be taken.
+func (q *replyQueue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+	q.notify()
+}
+
+func (q *replyQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next reply. It returns false once the queue is closed and empty.
+func (q *replyQueue) pop(ctx context.Context) (*ReturnValue, bool, error) {
+	for {
+		q.mutex.Lock()
+		if len(q.replies) > 0 {
+			rv := q.replies[0]
+			q.replies = q.replies[1:]
+			q.mutex.Unlock()
+			return rv, true, nil
+		}
+		closed := q.closed
+		q.mutex.Unlock()
+		if closed {
+			return nil, false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
+
+// NewMQConnection connects to the nodes returned by discover, with the async transport of their addresses,
+// and calls discover again every mqRefreshInterval to follow nodes joining and leaving.
+// If curve isn't nil, the connection uses CurveZMQ with these keys.
+func NewMQConnection(discover func() ([]string, error), curve *CurveClientKeys) (*MQConnection, error) {
+	c := &MQConnection{pending: make(map[string]*replyQueue)}
+	conn, err := dialAsync(discover, curve, c.deliver)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// send queues a call and returns the queue its replies are delivered to. A stream call gets replies
+// until the end of its stream, any other call a single reply. The queue is closed once the last reply
+// was delivered, or without a reply if the connection is closed first.
+func (c *MQConnection) send(parameters *CallParameters, stream bool) (*replyQueue, error) {
+	data, err := proto.Marshal(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("MQ connection is closed")
+	}
+	reply := newReplyQueue(stream)
+	c.pending[parameters.RequestId] = reply
+	c.mutex.Unlock()
+
+	// the mutex isn't held while sending, since transports may deliver replies from the sending goroutine
+	if err := c.conn.Send(data); err != nil {
+		c.forget(parameters.RequestId)
+		return nil, err
+	}
+	return reply, nil
+}
+
+// post sends a call that gets no reply.
+func (c *MQConnection) post(parameters *CallParameters) error {
+	data, err := proto.Marshal(parameters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal call parameters: %w", err)
+	}
+
+	c.mutex.Lock()
+	closed := c.closed
+	c.mutex.Unlock()
+	if closed {
+		return fmt.Errorf("MQ connection is closed")
+	}
+	return c.conn.Send(data)
+}
+
+// forget drops a pending call, so its reply is discarded if it arrives later.
+func (c *MQConnection) forget(requestID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.pending, requestID)
+}
+
+// Close stops the connection. Calls still waiting for a reply fail.
+func (c *MQConnection) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for requestID, reply := range c.pending {
+		reply.close()
+		delete(c.pending, requestID)
+	}
+	c.conn.Close()
+}
+
+func (c *MQConnection) deliver(data []byte) {
+	var rv ReturnValue
+	if err := proto.Unmarshal(data, &rv); err != nil {
+		log.Printf("Failed to unmarshal return value: %v\n", err)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	reply, ok := c.pending[rv.RequestId]
+	if !ok {
+		// the call was abandoned
+		return
+	}
+	reply.push(&rv)
+	if !reply.stream || rv.EndOfStream || rv.Code != ErrorCode_OK {
+		delete(c.pending, rv.RequestId)
+		reply.close()
+	}
+}
+
+// newRequestID returns a random correlation ID for a call.
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprintf("failed to generate request ID: %v", err))
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"context"
+	"io"
+	"log"
+	"reflect"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// mqDispatcher serves the methods of a gRPC service implementation over MQ,
+// through the handlers generated for the service.
+type mqDispatcher struct {
+	impl    interface{}
+	methods map[string]grpc.MethodDesc
+	streams map[string]grpc.StreamDesc
+}
+
+// NewMQDispatcher returns a MessageHandler calling the methods of impl, which implements
+// the service described by desc, e.g. NewMQDispatcher(&pb.TestService_ServiceDesc, servant).
+// Unary methods return their response. Server-streaming methods send a reply per message
+// as they go (see CallStream), and return a nil response.
+func NewMQDispatcher(desc *grpc.ServiceDesc, impl interface{}) MessageHandler {
+	if desc.HandlerType != nil {
+		handlerType := reflect.TypeOf(desc.HandlerType).Elem()
+		if !reflect.TypeOf(impl).Implements(handlerType) {
+			log.Fatalf("NewMQDispatcher: %v does not implement %v", reflect.TypeOf(impl), handlerType)
+		}
+	}
+
+	d := &mqDispatcher{
+		impl:    impl,
+		methods: make(map[string]grpc.MethodDesc, len(desc.Methods)),
+		streams: make(map[string]grpc.StreamDesc, len(desc.Streams)),
+	}
+	for _, method := range desc.Methods {
+		d.methods[method.MethodName] = method
+	}
+	for _, stream := range desc.Streams {
+		if stream.ClientStreams {
+			// a single MQ request can't carry a stream of requests
+			log.Printf("%v.%v streams requests and isn't served over MQ\n", desc.ServiceName, stream.StreamName)
+			continue
+		}
+		d.streams[stream.StreamName] = stream
+	}
+	return d.handle
+}
+
+func (d *mqDispatcher) handle(ctx context.Context, method string, parameters []byte) (proto.Message, error) {
+	if desc, ok := d.methods[method]; ok {
+		response, err := desc.Handler(d.impl, ctx, decodeMQParameters(ctx, parameters), nil)
+		if err != nil {
+			return nil, err
+		}
+		msg, _ := response.(proto.Message)
+		return msg, nil
+	}
+
+	if desc, ok := d.streams[method]; ok {
+		send, ok := ctx.Value(streamSenderKey{}).(func(*ReturnValue))
+		if !ok {
+			return nil, NewCallError(ErrorCode_INTERNAL_ERROR, "%v streams its response, which the MQ server doesn't support here", method)
+		}
+		stream := &mqServerStream{ctx: ctx, parameters: parameters, send: send}
+		if err := desc.Handler(d.impl, stream); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return nil, NewCallError(ErrorCode_METHOD_NOT_FOUND, "MQ message called unknown method: %v", method)
+}
+
+// decodeMQParameters returns the decoder passed to a generated unary handler.
+// The parameters are decoded as the call's content type, so any method accepts JSON.
+func decodeMQParameters(ctx context.Context, parameters []byte) func(interface{}) error {
+	return func(m interface{}) error {
+		if err := codecFromContext(ctx).Unmarshal(parameters, m.(proto.Message)); err != nil {
+			return NewCallError(ErrorCode_UNMARSHAL_ERROR, "failed to unmarshal parameters: %v", err)
+		}
+		return nil
+	}
+}
+
+// mqServerStream is the grpc.ServerStream a server-streaming method sends its messages to
+// when called over MQ. Its only request is the call's parameters.
+type mqServerStream struct {
+	ctx        context.Context
+	parameters []byte
+	received   bool
+	send       func(*ReturnValue)
+}
+
+func (s *mqServerStream) SetHeader(metadata.MD) error  { return nil }
+func (s *mqServerStream) SendHeader(metadata.MD) error { return nil }
+func (s *mqServerStream) SetTrailer(metadata.MD)       {}
+
+func (s *mqServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *mqServerStream) SendMsg(m interface{}) error {
+	data, err := codecFromContext(s.ctx).Marshal(m.(proto.Message))
+	if err != nil {
+		return NewCallError(ErrorCode_INTERNAL_ERROR, "failed to marshal streamed message: %v", err)
+	}
+	rv := &ReturnValue{Data: data}
+	if parameters, ok := CallParametersFromContext(s.ctx); ok {
+		rv.ContentType = parameters.ContentType
+	}
+	s.send(rv)
+	return nil
+}
+
+func (s *mqServerStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	return decodeMQParameters(s.ctx, s.parameters)(m)
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type dispatcherTestServer struct {
+	pb.UnimplementedTestServiceServer
+}
+
+func (dispatcherTestServer) HelloToUser(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String("Hello " + req.Value), nil
+}
+
+func (dispatcherTestServer) WaitAndRand(seconds *wrapperspb.Int32Value, stream pb.TestService_WaitAndRandServer) error {
+	for i := int32(0); i < seconds.Value; i++ {
+		if err := stream.Send(wrapperspb.Int32(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMQDispatcher(t *testing.T) {
+	handler := NewMQDispatcher(&pb.TestService_ServiceDesc, dispatcherTestServer{})
+	ctx := context.Background()
+
+	data, _ := proto.Marshal(wrapperspb.String("Aya"))
+	res, err := handler(ctx, "HelloToUser", data)
+	if err != nil || res.(*wrapperspb.StringValue).Value != "Hello Aya" {
+		t.Errorf("HelloToUser returned %v, %v", res, err)
+	}
+
+	var streamed []*ReturnValue
+	send := func(rv *ReturnValue) { streamed = append(streamed, rv) }
+	data, _ = proto.Marshal(wrapperspb.Int32(3))
+	rv := handleMQRequest(&CallParameters{Method: "WaitAndRand", Data: data}, handler, send)
+	if rv.Code != ErrorCode_OK || len(rv.Data) != 0 {
+		t.Fatalf("WaitAndRand returned %v", rv)
+	}
+	if len(streamed) != 3 {
+		t.Errorf("WaitAndRand streamed %v messages; want 3", len(streamed))
+	}
+
+	var callErr *CallError
+	_, err = handler(ctx, "Missing", nil)
+	if !errors.As(err, &callErr) || callErr.Code != ErrorCode_METHOD_NOT_FOUND {
+		t.Errorf("expected METHOD_NOT_FOUND, got %v", err)
+	}
+	_, err = handler(ctx, "HelloToUser", []byte{0xff, 0xff})
+	if !errors.As(err, &callErr) || callErr.Code != ErrorCode_UNMARSHAL_ERROR {
+		t.Errorf("expected UNMARSHAL_ERROR, got %v", err)
+	}
+}
+
+func TestMQDispatcherJSON(t *testing.T) {
+	handler := NewMQDispatcher(&pb.TestService_ServiceDesc, dispatcherTestServer{})
+
+	rv := handleMQRequest(&CallParameters{Method: "HelloToUser", Data: []byte(`"Aya"`), ContentType: ContentTypeJSON}, handler, nil)
+	if rv.Code != ErrorCode_OK || string(rv.Data) != `"Hello Aya"` || rv.ContentType != ContentTypeJSON {
+		t.Errorf("HelloToUser returned %v", rv)
+	}
+
+	var streamed []*ReturnValue
+	send := func(rv *ReturnValue) { streamed = append(streamed, rv) }
+	rv = handleMQRequest(&CallParameters{Method: "WaitAndRand", Data: []byte(`2`), ContentType: ContentTypeJSON}, handler, send)
+	if rv.Code != ErrorCode_OK || len(streamed) != 2 {
+		t.Fatalf("WaitAndRand returned %v after %v messages", rv, len(streamed))
+	}
+	res := &wrapperspb.Int32Value{}
+	if err := unmarshalReturnValue(streamed[1], res); err != nil || res.Value != 1 {
+		t.Errorf("second streamed message: %v (%q), %v", res, streamed[1].Data, err)
+	}
+
+	rv = handleMQRequest(&CallParameters{Method: "HelloToUser", Data: []byte(`{`), ContentType: ContentTypeJSON}, handler, nil)
+	if rv.Code != ErrorCode_UNMARSHAL_ERROR {
+		t.Errorf("malformed JSON: got code %v; want UNMARSHAL_ERROR", rv.Code)
+	}
+	rv = handleMQRequest(&CallParameters{Method: "HelloToUser", ContentType: "text/xml"}, handler, nil)
+	if rv.Code != ErrorCode_UNMARSHAL_ERROR {
+		t.Errorf("unsupported content type: got code %v; want UNMARSHAL_ERROR", rv.Code)
+	}
+}
@@ -0,0 +1,256 @@
+//go:build !nozmq
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pebbe/zmq4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var subscriberCounter atomic.Int64
+
+// Publisher broadcasts events to every subscriber of their topic over a PUB socket.
+// Services register the publisher's address as their RegistryServicePb.ProtocolPub endpoint,
+// so subscribers can find it. Events published while no subscriber is connected are dropped.
+type Publisher struct {
+	mutex  sync.Mutex
+	socket *zmq4.Socket
+}
+
+// NewPublisher binds a PUB socket, on a random port if listenPort is 0.
+func NewPublisher(listenPort int) (publisher *Publisher, listeningAddress string) {
+	socket, err := zmq4.NewSocket(zmq4.PUB)
+	if err != nil {
+		log.Fatalf("Failed to create a new zmq socket: %v", err)
+	}
+
+	if listenPort == 0 {
+		listeningAddress = "tcp://127.0.0.1:*"
+	} else {
+		listeningAddress = fmt.Sprintf("tcp://127.0.0.1:%v", listenPort)
+	}
+
+	err = socket.Bind(listeningAddress)
+	if err != nil {
+		log.Fatalf("Failed to bind a zmq socket: %v", err)
+	}
+
+	listeningAddress, err = socket.GetLastEndpoint()
+	if err != nil {
+		log.Fatalf("Failed to get listening address of zmq socket: %v", err)
+	}
+	return &Publisher{socket: socket}, listeningAddress
+}
+
+// Publish sends event to the subscribers of topic. Topics are dot-separated names, e.g. "cache.invalidate".
+func (p *Publisher) Publish(topic string, event proto.Message) error {
+	payload, err := anypb.New(event)
+	if err != nil {
+		return fmt.Errorf("failed to wrap event: %v", err)
+	}
+	data, err := proto.Marshal(&Event{Topic: topic, Payload: payload, Time: time.Now().UnixMilli()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// subscribers filter on the first frame
+	_, err = p.socket.SendMessage(topic, data)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %v", err)
+	}
+	return nil
+}
+
+func (p *Publisher) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.socket.Close()
+}
+
+type subscription struct {
+	topicPrefix string
+	handler     func(topic string, event proto.Message)
+}
+
+// Subscriber receives the events of the publishers returned by discover over a SUB socket.
+// Only the topics with a subscribed prefix are sent to it. Handlers run one at a time,
+// in the goroutine owning the socket, so a slow handler delays the events after it.
+type Subscriber struct {
+	mutex         sync.Mutex
+	control       *zmq4.Socket
+	subscriptions []subscription
+	// called when the subscriber connects to new publishers, see OnConnect
+	connectHandlers []func()
+	closed          bool
+}
+
+// NewSubscriber connects a SUB socket to the publishers returned by discover,
+// and calls discover again every mqRefreshInterval to follow publishers joining and leaving.
+func NewSubscriber(discover func() ([]string, error)) (*Subscriber, error) {
+	socket, err := zmq4.NewSocket(zmq4.SUB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ZeroMQ socket: %v", err)
+	}
+	connected := make(map[string]bool)
+	refreshNodes(socket, connected, discover)
+
+	// subscriptions change through the control socket, since only the owner goroutine may touch the SUB socket
+	commands, err := zmq4.NewSocket(zmq4.PULL)
+	if err != nil {
+		socket.Close()
+		return nil, fmt.Errorf("failed to create ZeroMQ socket: %v", err)
+	}
+	commandsAddress := fmt.Sprintf("inproc://subscriber-control-%d", subscriberCounter.Add(1))
+	control, err := zmq4.NewSocket(zmq4.PUSH)
+	if err == nil {
+		if err = commands.Bind(commandsAddress); err == nil {
+			err = control.Connect(commandsAddress)
+		}
+	}
+	if err != nil {
+		socket.Close()
+		commands.Close()
+		if control != nil {
+			control.Close()
+		}
+		return nil, fmt.Errorf("failed to set up subscriber control socket: %v", err)
+	}
+
+	s := &Subscriber{control: control}
+	go s.run(socket, commands, connected, discover)
+	return s, nil
+}
+
+// Subscribe calls handler with every event whose topic starts with topicPrefix.
+// An empty prefix subscribes to all topics.
+func (s *Subscriber) Subscribe(topicPrefix string, handler func(topic string, event proto.Message)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return fmt.Errorf("subscriber is closed")
+	}
+	s.subscriptions = append(s.subscriptions, subscription{topicPrefix: topicPrefix, handler: handler})
+	_, err := s.control.SendMessage("subscribe", topicPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %v: %v", topicPrefix, err)
+	}
+	return nil
+}
+
+// OnEvent subscribes a handler of events of type E to topics starting with topicPrefix.
+// Events of other types published on these topics are ignored.
+//
+//	OnEvent(subscriber, "crawl.", func(topic string, e *pb.CrawlCompleted) { ... })
+func OnEvent[E proto.Message](s *Subscriber, topicPrefix string, handler func(topic string, event E)) error {
+	return s.Subscribe(topicPrefix, func(topic string, event proto.Message) {
+		if typed, ok := event.(E); ok {
+			handler(topic, typed)
+		}
+	})
+}
+
+// OnConnect calls handler whenever the subscriber connects to publishers it discovered after it started.
+// Their events published before may have been missed, so state kept up to date by events should be reset.
+// Like event handlers, it runs in the goroutine owning the socket.
+func (s *Subscriber) OnConnect(handler func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connectHandlers = append(s.connectHandlers, handler)
+}
+
+// Close stops the subscriber. Events still in flight aren't delivered.
+func (s *Subscriber) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.control.SendMessage("close")
+	s.control.Close()
+}
+
+// run owns the SUB socket: it applies subscriptions and dispatches received events.
+func (s *Subscriber) run(socket *zmq4.Socket, commands *zmq4.Socket, connected map[string]bool, discover func() ([]string, error)) {
+	defer socket.Close()
+	defer commands.Close()
+
+	poller := zmq4.NewPoller()
+	poller.Add(socket, zmq4.POLLIN)
+	poller.Add(commands, zmq4.POLLIN)
+	lastRefresh := time.Now()
+	for {
+		polled, err := poller.Poll(mqRefreshInterval)
+		if err != nil {
+			log.Printf("Failed to poll subscriber sockets: %v\n", err)
+			continue
+		}
+		for _, item := range polled {
+			switch item.Socket {
+			case commands:
+				command, err := commands.RecvMessage(0)
+				if err != nil {
+					log.Printf("Failed to receive subscriber command: %v\n", err)
+					continue
+				}
+				if command[0] == "close" {
+					return
+				}
+				if err := socket.SetSubscribe(command[1]); err != nil {
+					log.Printf("Failed to subscribe to %v: %v\n", command[1], err)
+				}
+
+			case socket:
+				msg, err := socket.RecvMessageBytes(0)
+				if err != nil {
+					log.Printf("Failed to receive event: %v\n", err)
+					continue
+				}
+				s.dispatch(msg[len(msg)-1])
+			}
+		}
+		if time.Since(lastRefresh) >= mqRefreshInterval {
+			if refreshNodes(socket, connected, discover) > 0 {
+				s.mutex.Lock()
+				handlers := s.connectHandlers
+				s.mutex.Unlock()
+				for _, handler := range handlers {
+					handler()
+				}
+			}
+			lastRefresh = time.Now()
+		}
+	}
+}
+
+func (s *Subscriber) dispatch(data []byte) {
+	var event Event
+	if err := proto.Unmarshal(data, &event); err != nil {
+		log.Printf("Failed to unmarshal event: %v\n", err)
+		return
+	}
+	payload, err := event.Payload.UnmarshalNew()
+	if err != nil {
+		log.Printf("Failed to unmarshal event on %v: %v\n", event.Topic, err)
+		return
+	}
+
+	s.mutex.Lock()
+	subscriptions := s.subscriptions
+	s.mutex.Unlock()
+	for _, sub := range subscriptions {
+		if strings.HasPrefix(event.Topic, sub.topicPrefix) {
+			sub.handler(event.Topic, payload)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//go:build nozmq
+
+package common
+
+import (
+	"fmt"
+	"log"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Pub/sub runs over ZeroMQ only. In builds without it, publishers drop their events,
+// which NewPublisher logs once, and subscribers fail to start.
+
+type Publisher struct{}
+
+// NewPublisher returns a publisher dropping its events, and no address to register.
+func NewPublisher(listenPort int) (publisher *Publisher, listeningAddress string) {
+	log.Printf("Pub/sub requires ZeroMQ, events won't be published\n")
+	return &Publisher{}, ""
+}
+
+// Publish drops the event. It doesn't fail, so publishing services don't log every event they drop.
+func (p *Publisher) Publish(topic string, event proto.Message) error {
+	return nil
+}
+
+func (p *Publisher) Close() {}
+
+type Subscriber struct{}
+
+func NewSubscriber(discover func() ([]string, error)) (*Subscriber, error) {
+	return nil, fmt.Errorf("pub/sub requires ZeroMQ")
+}
+
+func (s *Subscriber) Subscribe(topicPrefix string, handler func(topic string, event proto.Message)) error {
+	return fmt.Errorf("pub/sub requires ZeroMQ")
+}
+
+func OnEvent[E proto.Message](s *Subscriber, topicPrefix string, handler func(topic string, event E)) error {
+	return s.Subscribe(topicPrefix, nil)
+}
+
+func (s *Subscriber) OnConnect(handler func()) {}
+
+func (s *Subscriber) Close() {}
@@ -1,15 +1,18 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"sort"
 	"time"
 
 	RegistryServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/client"
+	RegistryServicePb "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/common"
 	"github.com/TAULargeScaleWorkshop/AAG/utils"
-	"github.com/pebbe/zmq4"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -56,70 +59,255 @@ func RegisterAddress(serviceName string, registryAddresses []string, listeningAd
 	}
 }
 
-func BindMQToService(listenPort int, messageHandler func(method string, parameters []byte) (response proto.Message, err error)) (startMQ func(), listeningAddress string) {
-	socket, err := zmq4.NewSocket(zmq4.REP)
+// RegisterInstance registers a service instance with its endpoints, keyed by protocol
+// (e.g. RegistryServicePb.ProtocolMQ). The instance is identified by its gRPC address.
+// Endpoints with an empty address aren't registered.
+func RegisterInstance(serviceName string, registryAddresses []string, endpoints map[string]string) (unregister func()) {
+	registryClient := RegistryServiceClient.NewRegistryServiceClient(registryAddresses)
+
+	instance := &RegistryServicePb.ServiceInstance{InstanceId: endpoints[RegistryServicePb.ProtocolGRPC]}
+	protocols := make([]string, 0, len(endpoints))
+	for protocol, address := range endpoints {
+		// e.g. the pub endpoint of builds without ZeroMQ
+		if address != "" {
+			protocols = append(protocols, protocol)
+		}
+	}
+	sort.Strings(protocols)
+	for _, protocol := range protocols {
+		instance.Endpoints = append(instance.Endpoints, &RegistryServicePb.Endpoint{Protocol: protocol, Address: endpoints[protocol]})
+	}
+
+	err := registryClient.RegisterInstance(serviceName, instance)
 	if err != nil {
-		log.Fatalf("Failed to create a new zmq socket: %v", err)
+		utils.Logger.Fatalf("Failed to register to registry service: %v", err)
 	}
 
-	if listenPort == 0 {
-		listeningAddress = "tcp://127.0.0.1:*"
-	} else {
-		listeningAddress = fmt.Sprintf("tcp://127.0.0.1:%v", listenPort)
+	return func() {
+		registryClient.UnregisterInstance(serviceName, instance.InstanceId)
 	}
+}
 
-	err = socket.Bind(listeningAddress)
-	if err != nil {
-		log.Fatalf("Failed to bind a zmq socket: %v", err)
+// used when a service doesn't configure the workers or queue limit of a priority class
+const (
+	defaultMQWorkers         = 8
+	defaultMQPriorityWorkers = 2 // for the high and low priority classes
+	defaultMQQueueLimit      = 64
+)
+
+// MQLaneConfig configures the worker pool of a priority class.
+type MQLaneConfig struct {
+	Workers    int `yaml:"workers"`
+	QueueLimit int `yaml:"queueLimit"` // calls waiting for a worker. Further calls are rejected as OVERLOADED
+}
+
+// MQConfig configures the worker pools of an MQ server, one per priority class.
+type MQConfig struct {
+	High   MQLaneConfig `yaml:"high"`
+	Normal MQLaneConfig `yaml:"normal"`
+	Low    MQLaneConfig `yaml:"low"`
+
+	Transport string      `yaml:"transport"` // zmq (the default), grpc or inproc, see AsyncTransport
+	Curve     CurveConfig `yaml:"curve"`
+}
+
+func (config MQLaneConfig) withDefaults(workers int) MQLaneConfig {
+	if config.Workers <= 0 {
+		config.Workers = workers
+	}
+	if config.QueueLimit <= 0 {
+		config.QueueLimit = defaultMQQueueLimit
+	}
+	return config
+}
+
+// MessageHandler runs the method called by an MQ request. ctx carries the call's deadline,
+// and its metadata is available through metadata.FromIncomingContext, as in a gRPC call.
+type MessageHandler func(ctx context.Context, method string, parameters []byte) (response proto.Message, err error)
+
+type callParametersKey struct{}
+
+type streamSenderKey struct{}
+
+// CallParametersFromContext returns the parameters of the MQ call a MessageHandler runs.
+func CallParametersFromContext(ctx context.Context) (*CallParameters, bool) {
+	parameters, ok := ctx.Value(callParametersKey{}).(*CallParameters)
+	return parameters, ok
+}
+
+// mqRequest is a request received by an MQ server, with the function replying to its caller.
+type mqRequest struct {
+	reply      AsyncReply
+	parameters *CallParameters
+}
+
+// BindMQToService listens for MQ calls, with the async transport of config, and serves them with a pool
+// of workers per priority class. Requests wait for a worker of their class in a queue, and are rejected
+// with OVERLOADED while the queue is full, so cheap high-priority calls don't wait behind long ones
+// and load can't pile up without bound.
+// Every request but a one-way call gets a ReturnValue reply, which carries the error code when the call failed,
+// and requests whose deadline passed while queued are dropped with DEADLINE_EXCEEDED.
+// With config.Curve enabled, calls are encrypted and only authorized clients may connect.
+func BindMQToService(listenPort int, config MQConfig, messageHandler MessageHandler) (startMQ func(), listeningAddress string) {
+	lanes := map[Priority]MQLaneConfig{
+		Priority_PRIORITY_HIGH:   config.High.withDefaults(defaultMQPriorityWorkers),
+		Priority_PRIORITY_NORMAL: config.Normal.withDefaults(defaultMQWorkers),
+		Priority_PRIORITY_LOW:    config.Low.withDefaults(defaultMQPriorityWorkers),
 	}
 
-	listeningAddress, err = socket.GetLastEndpoint()
+	transport, err := asyncTransportByName(config.Transport)
+	if err != nil {
+		log.Fatalf("Failed to select async transport: %v", err)
+	}
+	listener, listeningAddress, err := transport.Listen(listenPort, config.Curve)
 	if err != nil {
-		log.Fatalf("Failed to get listening address of zmq socket: %v", err)
+		log.Fatalf("Failed to listen for MQ calls: %v", err)
 	}
+
 	startMQ = func() {
-		for {
-			time.Sleep(10 * time.Second)
-			data, readErr := socket.RecvBytes(0)
-			if readErr != nil {
-				log.Printf("Failed to receive bytes from MQ socket: %v\n", readErr)
-				continue
-			}
-			if len(data) == 0 {
-				continue
+		queues := make(map[Priority]chan mqRequest, len(lanes))
+		for priority, lane := range lanes {
+			queues[priority] = make(chan mqRequest, lane.QueueLimit)
+			for i := 0; i < lane.Workers; i++ {
+				go runMQWorker(queues[priority], messageHandler)
 			}
-			utils.Logger.Printf("data len: %v\n", len(data))
-
-			go func(data []byte) {
-
-				var parameters CallParameters
-				if err := proto.Unmarshal(data, &parameters); err != nil {
-					log.Printf("Failed to unmarshal data: %v", err)
-					return
-				}
-
-				response, err := messageHandler(parameters.Method, parameters.Data)
-				if err != nil {
-					log.Printf("Message handler error: %v\n", err)
-					return
-				}
-
-				returnData, err := proto.Marshal(response)
-				if err != nil {
-					log.Printf("Failed to marshal ReturnValue: %v\n", err)
-					return
-				}
-				_, sendErr := socket.SendBytes(returnData, 0)
-				if sendErr != nil {
-					log.Printf("Failed to send response: %v\n", sendErr)
-				}
-			}(data)
 		}
+
+		listener.Serve(func(data []byte, reply AsyncReply) {
+			if rejection, replyTo := admitMQRequest(queues, reply, data); rejection != nil {
+				reply(replyTo, rejection)
+			}
+		})
 	}
 
 	return startMQ, listeningAddress
 }
 
+// admitMQRequest queues a request for the workers of its priority class. It never blocks: a request that
+// can't be parsed, or whose queue is full, is rejected with the returned ReturnValue, which is nil
+// if the request was queued or is a rejected one-way call.
+func admitMQRequest(queues map[Priority]chan mqRequest, reply AsyncReply, data []byte) (rejection *ReturnValue, replyTo string) {
+	var parameters CallParameters
+	if err := proto.Unmarshal(data, &parameters); err != nil {
+		log.Printf("Failed to unmarshal data: %v", err)
+		return newErrorReturnValue(NewCallError(ErrorCode_UNMARSHAL_ERROR, "failed to unmarshal call parameters: %v", err)), ""
+	}
+
+	queue, ok := queues[parameters.Priority]
+	if !ok {
+		queue = queues[Priority_PRIORITY_NORMAL]
+	}
+	select {
+	case queue <- mqRequest{reply: reply, parameters: &parameters}:
+		return nil, ""
+	default:
+	}
+	log.Printf("Rejecting %v: the %v queue is full\n", parameters.Method, parameters.Priority)
+	if parameters.CallType == CallType_ONE_WAY {
+		return nil, ""
+	}
+	rejection = newErrorReturnValue(NewCallError(ErrorCode_OVERLOADED, "too many %v calls waiting, try again later", parameters.Priority))
+	rejection.RequestId = parameters.RequestId
+	rejection.EndOfStream = true
+	return rejection, parameters.ReplyTo
+}
+
+// runMQWorker runs requests from the queue of a priority class.
+func runMQWorker(requests <-chan mqRequest, messageHandler MessageHandler) {
+	for req := range requests {
+		parameters := req.parameters
+		// streaming methods reply once per streamed message before the final reply
+		send := func(streamed *ReturnValue) {
+			if parameters.CallType != CallType_ONE_WAY {
+				streamed.RequestId = parameters.RequestId
+				req.reply(parameters.ReplyTo, streamed)
+			}
+		}
+		var rv *ReturnValue
+		if parameters.CallType == CallType_BATCH {
+			rv = handleMQBatch(parameters, messageHandler)
+		} else {
+			rv = handleMQRequest(parameters, messageHandler, send)
+		}
+		if parameters.CallType == CallType_ONE_WAY {
+			// the caller doesn't wait for a reply. Errors were logged by handleMQRequest
+			continue
+		}
+		rv.RequestId = parameters.RequestId
+		rv.EndOfStream = true
+		req.reply(parameters.ReplyTo, rv)
+	}
+}
+
+// handleMQRequest runs a single MQ call and wraps its result, or its error, in a ReturnValue.
+// Calls whose deadline already passed are dropped without running them.
+// send, if not nil, is how a streaming method sends its messages before it returns.
+// The response is encoded as the call's content type.
+func handleMQRequest(parameters *CallParameters, messageHandler MessageHandler, send func(*ReturnValue)) (rv *ReturnValue) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Message handler panicked: %v\n", r)
+			rv = newErrorReturnValue(NewCallError(ErrorCode_INTERNAL_ERROR, "message handler panicked: %v", r))
+		}
+	}()
+
+	codec, err := CodecFor(parameters.ContentType)
+	if err != nil {
+		return newErrorReturnValue(NewCallError(ErrorCode_UNMARSHAL_ERROR, "%v", err))
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(parameters.Metadata))
+	ctx = context.WithValue(ctx, callParametersKey{}, parameters)
+	if send != nil {
+		ctx = context.WithValue(ctx, streamSenderKey{}, send)
+	}
+	if parameters.Deadline != 0 {
+		deadline := time.UnixMilli(parameters.Deadline)
+		if !time.Now().Before(deadline) {
+			return newErrorReturnValue(NewCallError(ErrorCode_DEADLINE_EXCEEDED, "deadline of %v passed before it was processed", parameters.Method))
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	response, err := messageHandler(ctx, parameters.Method, parameters.Data)
+	if err != nil {
+		log.Printf("Message handler error: %v\n", err)
+		return newErrorReturnValue(err)
+	}
+	if response == nil {
+		// streaming methods sent their messages already
+		return &ReturnValue{}
+	}
+
+	responseData, err := codec.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshal response: %v\n", err)
+		return newErrorReturnValue(NewCallError(ErrorCode_INTERNAL_ERROR, "failed to marshal response: %v", err))
+	}
+	return &ReturnValue{Data: responseData, ContentType: parameters.ContentType}
+}
+
+// splitEnvelope splits a message received on a ROUTER socket into its routing envelope
+// (everything up to and including the empty delimiter frame) and the payload.
+func splitEnvelope(msg [][]byte) (envelope [][]byte, data []byte) {
+	for i, frame := range msg {
+		if len(frame) == 0 {
+			envelope = msg[:i+1]
+			if i+1 < len(msg) {
+				data = msg[len(msg)-1]
+			}
+			return envelope, data
+		}
+	}
+	// no delimiter: only the identity frame added by the ROUTER
+	if len(msg) < 2 {
+		return msg, nil
+	}
+	return msg[:1], msg[len(msg)-1]
+}
+
 func NewMarshaledCallParameter(method string, msg proto.Message) ([]byte, error) {
 	params := &CallParameters{
 		Method: method,
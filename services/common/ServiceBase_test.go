@@ -0,0 +1,196 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func echoHandler(ctx context.Context, method string, parameters []byte) (proto.Message, error) {
+	switch method {
+	case "Token":
+		md, _ := metadata.FromIncomingContext(ctx)
+		return wrapperspb.String(md.Get("token")[0]), nil
+	case "Echo":
+		req := &wrapperspb.StringValue{}
+		if err := proto.Unmarshal(parameters, req); err != nil {
+			return nil, NewCallError(ErrorCode_UNMARSHAL_ERROR, "failed to unmarshal parameters: %v", err)
+		}
+		return req, nil
+	case "Fail":
+		return nil, fmt.Errorf("servant failed")
+	case "Panic":
+		panic("boom")
+	}
+	return nil, NewCallError(ErrorCode_METHOD_NOT_FOUND, "unknown method: %v", method)
+}
+
+func TestHandleMQRequest(t *testing.T) {
+	echo, err := newCallParameters(context.Background(), "Echo", wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("failed to marshal call parameters: %v", err)
+	}
+	rv := handleMQRequest(echo, echoHandler, nil)
+	if rv.Code != ErrorCode_OK || rv.Error != "" {
+		t.Fatalf("Echo returned error: %v %v", rv.Code, rv.Error)
+	}
+	res := &wrapperspb.StringValue{}
+	if err := proto.Unmarshal(rv.Data, res); err != nil || res.Value != "hello" {
+		t.Errorf("Echo returned %v (%v); want hello", res.Value, err)
+	}
+
+	tests := []struct {
+		method string
+		code   ErrorCode
+	}{
+		{"Fail", ErrorCode_SERVANT_ERROR},
+		{"Missing", ErrorCode_METHOD_NOT_FOUND},
+		{"Panic", ErrorCode_INTERNAL_ERROR},
+	}
+	for _, tt := range tests {
+		rv := handleMQRequest(&CallParameters{Method: tt.method}, echoHandler, nil)
+		if rv.Code != tt.code || rv.Error == "" {
+			t.Errorf("%s: got code %v (%q); want %v", tt.method, rv.Code, rv.Error, tt.code)
+		}
+	}
+}
+
+func TestHandleMQRequestDeadline(t *testing.T) {
+	expired := &CallParameters{Method: "Echo", Deadline: time.Now().Add(-time.Second).UnixMilli()}
+	rv := handleMQRequest(expired, echoHandler, nil)
+	if rv.Code != ErrorCode_DEADLINE_EXCEEDED {
+		t.Errorf("expired call: got code %v; want %v", rv.Code, ErrorCode_DEADLINE_EXCEEDED)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "token", "secret")
+	parameters, err := newCallParameters(ctx, "Token", wrapperspb.String(""))
+	if err != nil {
+		t.Fatalf("failed to marshal call parameters: %v", err)
+	}
+	if parameters.RequestId == "" || parameters.Deadline == 0 {
+		t.Errorf("call parameters missing request ID or deadline: %v", parameters)
+	}
+	rv = handleMQRequest(parameters, echoHandler, nil)
+	res := &wrapperspb.StringValue{}
+	if err := proto.Unmarshal(rv.Data, res); err != nil || res.Value != "secret" {
+		t.Errorf("Token returned %v (%v %v); want secret", res.Value, rv.Code, rv.Error)
+	}
+}
+
+func TestErrorFromReturnValue(t *testing.T) {
+	if err := errorFromReturnValue(&ReturnValue{Data: []byte("x")}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := errorFromReturnValue(&ReturnValue{Code: ErrorCode_METHOD_NOT_FOUND, Error: "no such method"})
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Code != ErrorCode_METHOD_NOT_FOUND {
+		t.Errorf("expected METHOD_NOT_FOUND CallError, got %v", err)
+	}
+}
+
+func TestSplitEnvelope(t *testing.T) {
+	msg := [][]byte{[]byte("id"), {}, []byte("payload")}
+	envelope, data := splitEnvelope(msg)
+	if len(envelope) != 2 || string(data) != "payload" {
+		t.Errorf("splitEnvelope(%q) = %q, %q", msg, envelope, data)
+	}
+}
+
+func TestAdmitMQRequest(t *testing.T) {
+	queues := map[Priority]chan mqRequest{
+		Priority_PRIORITY_HIGH:   make(chan mqRequest, 1),
+		Priority_PRIORITY_NORMAL: make(chan mqRequest, 1),
+		Priority_PRIORITY_LOW:    make(chan mqRequest, 1),
+	}
+	admit := func(parameters *CallParameters) *ReturnValue {
+		data, err := proto.Marshal(parameters)
+		if err != nil {
+			t.Fatalf("failed to marshal call parameters: %v", err)
+		}
+		rejection, _ := admitMQRequest(queues, nil, data)
+		return rejection
+	}
+
+	high, err := newCallParameters(WithPriority(context.Background(), Priority_PRIORITY_HIGH), "Echo", wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("failed to marshal call parameters: %v", err)
+	}
+	if rv := admit(high); rv != nil {
+		t.Fatalf("high priority call rejected: %v", rv)
+	}
+	if req := <-queues[Priority_PRIORITY_HIGH]; req.parameters.RequestId != high.RequestId {
+		t.Errorf("high priority queue got %v; want %v", req.parameters.RequestId, high.RequestId)
+	}
+
+	if rv := admit(&CallParameters{Method: "Echo", RequestId: "1"}); rv != nil {
+		t.Fatalf("normal priority call rejected: %v", rv)
+	}
+	rv := admit(&CallParameters{Method: "Echo", RequestId: "2"})
+	if rv == nil || rv.Code != ErrorCode_OVERLOADED || rv.RequestId != "2" || !rv.EndOfStream {
+		t.Errorf("call to a full queue: got %v; want OVERLOADED reply to 2", rv)
+	}
+	if rv := admit(&CallParameters{Method: "Echo", CallType: CallType_ONE_WAY}); rv != nil {
+		t.Errorf("one-way call to a full queue got a reply: %v", rv)
+	}
+	if rv := admit(&CallParameters{Method: "Echo", Priority: Priority_PRIORITY_LOW}); rv != nil {
+		t.Errorf("low priority call rejected while its queue is empty: %v", rv)
+	}
+
+	if rv, _ := admitMQRequest(queues, nil, []byte{0xff}); rv == nil || rv.Code != ErrorCode_UNMARSHAL_ERROR {
+		t.Errorf("malformed call: got %v; want UNMARSHAL_ERROR", rv)
+	}
+}
+
+// capturedConn is an AsyncConn keeping the calls sent over it
+type capturedConn struct {
+	sent [][]byte
+}
+
+func (c *capturedConn) Send(data []byte) error {
+	c.sent = append(c.sent, data)
+	return nil
+}
+
+func (c *capturedConn) Close() {}
+
+func TestCallReplyTo(t *testing.T) {
+	captured := &capturedConn{}
+	conn := &MQConnection{pending: make(map[string]*replyQueue), conn: captured}
+	requestID, err := CallReplyTo(context.Background(), connectedService{conn}, "Echo", wrapperspb.String("hello"), "tcp://127.0.0.1:5000")
+	if err != nil {
+		t.Fatalf("CallReplyTo failed: %v", err)
+	}
+	if len(conn.pending) != 0 {
+		t.Errorf("the caller waits for a reply pushed elsewhere")
+	}
+
+	var parameters CallParameters
+	if len(captured.sent) != 1 || proto.Unmarshal(captured.sent[0], &parameters) != nil {
+		t.Fatalf("sent %v calls; want 1", len(captured.sent))
+	}
+	requests := make(chan mqRequest, 1)
+	replies := make(chan []byte, 1)
+	requests <- mqRequest{parameters: &parameters, reply: func(replyTo string, rv *ReturnValue) {
+		if replyTo != "tcp://127.0.0.1:5000" {
+			t.Errorf("reply sent to %q; want the reply-to address", replyTo)
+		}
+		data, _ := proto.Marshal(rv)
+		replies <- data
+	}}
+	close(requests)
+	runMQWorker(requests, echoHandler)
+
+	replyID, resp, err := ReplyToResult[*wrapperspb.StringValue](<-replies)
+	if err != nil || replyID != requestID || resp.Value != "hello" {
+		t.Errorf("ReplyToResult returned %v, %v, %v; want hello for %v", replyID, resp, err, requestID)
+	}
+}
@@ -2,25 +2,30 @@ package common
 
 import (
 	"fmt"
-	"log"
 	"math/rand"
-	"strings"
+	"sync"
 	"time"
 
 	RegistryServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/client"
-
-	zmq4 "github.com/pebbe/zmq4"
+	RegistryServicePb "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/common"
 	"google.golang.org/grpc"
 )
 
 type ServiceClientBase[client_t any] struct {
+	ServiceName       string
 	RegistryAddresses []string
 	CreateClient      func(grpc.ClientConnInterface) client_t
 	RegistryClient    *RegistryServiceClient.RegistryServiceClient
+	// MQ calls use CurveZMQ if enabled. Set it before the first async call
+	Curve CurveConfig
+
+	mqMutex      sync.Mutex
+	mqConnection *MQConnection
 }
 
-func NewServiceClientBase[client_t any](registryClient *RegistryServiceClient.RegistryServiceClient, addresses []string, createClient func(grpc.ClientConnInterface) client_t) *ServiceClientBase[client_t] {
+func NewServiceClientBase[client_t any](serviceName string, registryClient *RegistryServiceClient.RegistryServiceClient, addresses []string, createClient func(grpc.ClientConnInterface) client_t) *ServiceClientBase[client_t] {
 	return &ServiceClientBase[client_t]{
+		ServiceName:       serviceName,
 		RegistryAddresses: addresses,
 		CreateClient:      createClient,
 		RegistryClient:    registryClient,
@@ -28,7 +33,7 @@ func NewServiceClientBase[client_t any](registryClient *RegistryServiceClient.Re
 }
 
 func (obj *ServiceClientBase[client_t]) pickNode(serviceName string) (string, error) {
-	nodes, err := obj.RegistryClient.Discover(serviceName)
+	nodes, err := obj.RegistryClient.DiscoverEndpoints(serviceName, RegistryServicePb.ProtocolGRPC)
 	if err != nil {
 		return "", fmt.Errorf("failed to discover nodes: %v", err)
 	}
@@ -47,10 +52,24 @@ func (obj *ServiceClientBase[client_t]) Connect(serviceName string) (res client_
 		var empty client_t
 		return empty, nil, fmt.Errorf("failed to pickNode: %v", err)
 	}
-	conn, err := grpc.Dial(NodeAddress, grpc.WithInsecure(), grpc.WithBlock())
+	return obj.ConnectTo(NodeAddress)
+}
+
+// Nodes returns the gRPC addresses of the nodes of the service, for calls about the state of each node.
+func (obj *ServiceClientBase[client_t]) Nodes() ([]string, error) {
+	nodes, err := obj.RegistryClient.DiscoverEndpoints(obj.ServiceName, RegistryServicePb.ProtocolGRPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover nodes: %v", err)
+	}
+	return nodes, nil
+}
+
+// ConnectTo connects to the node of the service at a gRPC address, e.g. one of Nodes.
+func (obj *ServiceClientBase[client_t]) ConnectTo(address string) (res client_t, closeFunc func(), err error) {
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock())
 	if err != nil {
 		var empty client_t
-		return empty, nil, fmt.Errorf("failed to connect client to %v: %v", NodeAddress, err)
+		return empty, nil, fmt.Errorf("failed to connect client to %v: %v", address, err)
 	}
 	c := obj.CreateClient(conn)
 	return c, func() { conn.Close() }, nil
@@ -58,51 +77,39 @@ func (obj *ServiceClientBase[client_t]) Connect(serviceName string) (res client_
 
 // getMQNodes retrieves the list of MQ nodes from the registry
 func (obj *ServiceClientBase[client_t]) getMQNodes() ([]string, error) {
-	nodes, err := obj.RegistryClient.Discover("TestServiceMQ")
+	nodes, err := obj.RegistryClient.DiscoverEndpoints(obj.ServiceName, RegistryServicePb.ProtocolMQ)
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover MQ nodes: %v", err)
 	}
 	if len(nodes) == 0 {
 		return nil, fmt.Errorf("no MQ nodes available")
 	}
-
-	// Use the helper function to extract only the MQ addresses
-	mqAddresses := extractMQAddresses(nodes)
-	return mqAddresses, nil
+	return nodes, nil
 }
 
-// ConnectMQ connects to all MQ nodes and returns a ZeroMQ socket
-func (obj *ServiceClientBase[client_t]) ConnectMQ() (socket *zmq4.Socket, err error) {
-	nodes, err := obj.getMQNodes()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get MQ nodes: %v", err)
+// ConnectMQ returns the client's connection to the MQ nodes, connecting on first use.
+// All the async calls of the client share the connection.
+func (obj *ServiceClientBase[client_t]) ConnectMQ() (*MQConnection, error) {
+	obj.mqMutex.Lock()
+	defer obj.mqMutex.Unlock()
+	if obj.mqConnection != nil {
+		return obj.mqConnection, nil
 	}
-	socket, err = zmq4.NewSocket(zmq4.REQ)
+	curve, err := obj.Curve.ClientKeys(obj.ServiceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ZeroMQ socket: %v", err)
+		return nil, fmt.Errorf("failed to load CurveZMQ keys: %v", err)
 	}
-
-	// Connect to all the nodes
-	for _, node := range nodes {
-		err := socket.Connect(node)
-		if err != nil {
-			socket.Close() // Clean up if there is an error
-			return nil, fmt.Errorf("failed to connect to MQ node %v: %v", node, err)
-		}
-		log.Printf("Connected to MQ node: %s", node)
+	conn, err := NewMQConnection(obj.getMQNodes, curve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQ nodes: %v", err)
 	}
-
-	return socket, nil
+	obj.mqConnection = conn
+	return conn, nil
 }
 
-// extractMQAddresses takes a list of nodes with appended addresses and returns only the MQ addresses.
-func extractMQAddresses(nodes []string) []string {
-	mqAddresses := make([]string, 0, len(nodes))
-	for _, node := range nodes {
-		parts := strings.Split(node, "@")
-		if len(parts) > 0 {
-			mqAddresses = append(mqAddresses, parts[0]) // Take only the MQ address part
-		}
-	}
-	return mqAddresses
+// NewSubscriber returns a subscriber to the events published by the nodes of the service.
+func (obj *ServiceClientBase[client_t]) NewSubscriber() (*Subscriber, error) {
+	return NewSubscriber(func() ([]string, error) {
+		return obj.RegistryClient.DiscoverEndpoints(obj.ServiceName, RegistryServicePb.ProtocolPub)
+	})
 }
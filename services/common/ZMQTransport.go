@@ -0,0 +1,390 @@
+//go:build !nozmq
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TAULargeScaleWorkshop/AAG/utils"
+	"github.com/pebbe/zmq4"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	registerAsyncTransport("zmq", "tcp", zmqTransport{})
+}
+
+var (
+	mqServerCounter     atomic.Int64
+	mqConnectionCounter atomic.Int64
+)
+
+// zmqTransport carries MQ calls over ZeroMQ: servers bind a ROUTER socket and clients connect a DEALER
+// socket to all the nodes of a service, which spreads their calls over the nodes.
+type zmqTransport struct{}
+
+// zmqListener owns a ROUTER socket. Replies are sent from many goroutines, and ZeroMQ sockets aren't
+// thread-safe, so they are pushed (prefixed with the request's envelope) to an inproc socket that the
+// goroutine owning the ROUTER forwards to the caller. Requests with a reply-to address are answered
+// on that address instead.
+type zmqListener struct {
+	frontend *zmq4.Socket
+	replies  *zmq4.Socket
+
+	mutex   sync.Mutex
+	sink    *zmq4.Socket
+	replyTo map[string]*zmq4.Socket
+}
+
+// Listen binds a ROUTER socket. With curve enabled, calls are encrypted and only authorized clients may connect.
+func (zmqTransport) Listen(listenPort int, curve CurveConfig) (AsyncListener, string, error) {
+	frontend, err := zmq4.NewSocket(zmq4.ROUTER)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create a new zmq socket: %v", err)
+	}
+
+	mqServer := mqServerCounter.Add(1)
+	if curve.Enabled {
+		err = curve.secureServer(frontend, fmt.Sprintf("mq-%d", mqServer))
+		if err != nil {
+			frontend.Close()
+			return nil, "", fmt.Errorf("failed to set up CurveZMQ: %v", err)
+		}
+	}
+
+	var listeningAddress string
+	if listenPort == 0 {
+		listeningAddress = "tcp://127.0.0.1:*"
+	} else {
+		listeningAddress = fmt.Sprintf("tcp://127.0.0.1:%v", listenPort)
+	}
+
+	err = frontend.Bind(listeningAddress)
+	if err == nil {
+		listeningAddress, err = frontend.GetLastEndpoint()
+	}
+	if err != nil {
+		frontend.Close()
+		return nil, "", fmt.Errorf("failed to bind a zmq socket: %v", err)
+	}
+
+	// inproc endpoints must be bound before the sink connects to them
+	replies, err := zmq4.NewSocket(zmq4.PULL)
+	if err != nil {
+		frontend.Close()
+		return nil, "", fmt.Errorf("failed to create a new zmq socket: %v", err)
+	}
+	repliesAddress := fmt.Sprintf("inproc://mq-replies-%d", mqServer)
+	sink, err := zmq4.NewSocket(zmq4.PUSH)
+	if err == nil {
+		if err = replies.Bind(repliesAddress); err == nil {
+			err = sink.Connect(repliesAddress)
+		}
+	}
+	if err != nil {
+		frontend.Close()
+		replies.Close()
+		if sink != nil {
+			sink.Close()
+		}
+		return nil, "", fmt.Errorf("failed to set up MQ reply sink: %v", err)
+	}
+
+	listener := &zmqListener{frontend: frontend, replies: replies, sink: sink, replyTo: make(map[string]*zmq4.Socket)}
+	return listener, listeningAddress, nil
+}
+
+func (l *zmqListener) Serve(handle func(data []byte, reply AsyncReply)) {
+	poller := zmq4.NewPoller()
+	poller.Add(l.frontend, zmq4.POLLIN)
+	poller.Add(l.replies, zmq4.POLLIN)
+	for {
+		polled, err := poller.Poll(-1)
+		if err != nil {
+			log.Printf("Failed to poll MQ sockets: %v\n", err)
+			continue
+		}
+		for _, item := range polled {
+			switch item.Socket {
+			case l.frontend:
+				msg, readErr := l.frontend.RecvMessageBytes(0)
+				if readErr != nil {
+					log.Printf("Failed to receive bytes from MQ socket: %v\n", readErr)
+					continue
+				}
+				envelope, data := splitEnvelope(msg)
+				if len(data) == 0 {
+					continue
+				}
+				utils.Logger.Printf("data len: %v\n", len(data))
+				handle(data, func(replyTo string, rv *ReturnValue) {
+					l.reply(envelope, replyTo, rv)
+				})
+
+			case l.replies:
+				msg, readErr := l.replies.RecvMessageBytes(0)
+				if readErr != nil {
+					log.Printf("Failed to receive reply from MQ worker: %v\n", readErr)
+					continue
+				}
+				_, sendErr := l.frontend.SendMessage(msg)
+				if sendErr != nil {
+					log.Printf("Failed to send response: %v\n", sendErr)
+				}
+			}
+		}
+	}
+}
+
+func (l *zmqListener) reply(envelope [][]byte, replyTo string, rv *ReturnValue) {
+	returnData, err := proto.Marshal(rv)
+	if err != nil {
+		log.Printf("Failed to marshal ReturnValue: %v\n", err)
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if replyTo == "" {
+		_, err = l.sink.SendMessage(envelope, returnData)
+	} else {
+		var socket *zmq4.Socket
+		socket, err = l.replySocket(replyTo)
+		if err == nil {
+			_, err = socket.SendBytes(returnData, zmq4.DONTWAIT)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to send response: %v\n", err)
+	}
+}
+
+// replySocket returns a PUSH socket connected to a reply-to address, opening it on first use. Called with the mutex held.
+func (l *zmqListener) replySocket(address string) (*zmq4.Socket, error) {
+	if socket, ok := l.replyTo[address]; ok {
+		return socket, nil
+	}
+	socket, err := zmq4.NewSocket(zmq4.PUSH)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new zmq socket: %v", err)
+	}
+	// don't hold replies to callers that went away
+	socket.SetLinger(0)
+	if err := socket.Connect(address); err != nil {
+		socket.Close()
+		return nil, fmt.Errorf("failed to connect to reply-to address %v: %v", address, err)
+	}
+	l.replyTo[address] = socket
+	return socket, nil
+}
+
+// zmqConn multiplexes the calls of a client over a single DEALER socket, owned by a single goroutine.
+// Callers hand it their calls through an inproc PUSH socket, which they share under the mutex.
+type zmqConn struct {
+	mutex  sync.Mutex
+	outbox *zmq4.Socket
+	closed bool
+}
+
+// Dial connects a DEALER socket to the nodes returned by discover. If curve isn't nil, the connection uses CurveZMQ.
+func (zmqTransport) Dial(discover func() ([]string, error), curve *CurveClientKeys, deliver func(data []byte)) (AsyncConn, error) {
+	nodes, err := discover()
+	if err != nil {
+		return nil, err
+	}
+
+	dealer, err := zmq4.NewSocket(zmq4.DEALER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ZeroMQ socket: %v", err)
+	}
+	// don't keep unanswered requests around once the connection is closed
+	dealer.SetLinger(0)
+	if curve != nil {
+		if err := curve.secureClient(dealer); err != nil {
+			dealer.Close()
+			return nil, fmt.Errorf("failed to set up CurveZMQ: %v", err)
+		}
+	}
+	connected := make(map[string]bool)
+	for _, node := range nodes {
+		if err := dealer.Connect(node); err != nil {
+			dealer.Close()
+			return nil, fmt.Errorf("failed to connect to MQ node %v: %v", node, err)
+		}
+		connected[node] = true
+		log.Printf("Connected to MQ node: %s", node)
+	}
+
+	// inproc endpoints must be bound before the outbox connects to them
+	inbox, err := zmq4.NewSocket(zmq4.PULL)
+	if err != nil {
+		dealer.Close()
+		return nil, fmt.Errorf("failed to create ZeroMQ socket: %v", err)
+	}
+	inboxAddress := fmt.Sprintf("inproc://mq-outbox-%d", mqConnectionCounter.Add(1))
+	outbox, err := zmq4.NewSocket(zmq4.PUSH)
+	if err == nil {
+		if err = inbox.Bind(inboxAddress); err == nil {
+			err = outbox.Connect(inboxAddress)
+		}
+	}
+	if err != nil {
+		dealer.Close()
+		inbox.Close()
+		if outbox != nil {
+			outbox.Close()
+		}
+		return nil, fmt.Errorf("failed to set up MQ outbox: %v", err)
+	}
+
+	conn := &zmqConn{outbox: outbox}
+	go conn.run(dealer, inbox, connected, discover, deliver)
+	return conn, nil
+}
+
+func (c *zmqConn) Send(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return fmt.Errorf("MQ connection is closed")
+	}
+	if _, err := c.outbox.SendBytes(data, 0); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+func (c *zmqConn) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	// an empty message tells the owner goroutine to stop
+	c.outbox.SendBytes(nil, 0)
+	c.outbox.Close()
+}
+
+// run owns the DEALER socket: it forwards queued requests to the MQ nodes and delivers their replies.
+func (c *zmqConn) run(dealer *zmq4.Socket, inbox *zmq4.Socket, connected map[string]bool, discover func() ([]string, error), deliver func(data []byte)) {
+	defer dealer.Close()
+	defer inbox.Close()
+
+	poller := zmq4.NewPoller()
+	poller.Add(dealer, zmq4.POLLIN)
+	poller.Add(inbox, zmq4.POLLIN)
+	lastRefresh := time.Now()
+	for {
+		polled, err := poller.Poll(mqRefreshInterval)
+		if err != nil {
+			log.Printf("Failed to poll MQ sockets: %v\n", err)
+			continue
+		}
+		for _, item := range polled {
+			switch item.Socket {
+			case inbox:
+				data, err := inbox.RecvBytes(0)
+				if err != nil {
+					log.Printf("Failed to receive queued request: %v\n", err)
+					continue
+				}
+				if len(data) == 0 {
+					return
+				}
+				// the empty frame makes the request look like it was sent by a REQ socket
+				if _, err := dealer.SendMessage("", data); err != nil {
+					log.Printf("Failed to send message: %v\n", err)
+				}
+
+			case dealer:
+				msg, err := dealer.RecvMessageBytes(0)
+				if err != nil {
+					log.Printf("Failed to receive response: %v\n", err)
+					continue
+				}
+				deliver(msg[len(msg)-1])
+			}
+		}
+		if time.Since(lastRefresh) >= mqRefreshInterval {
+			refreshNodes(dealer, connected, discover)
+			lastRefresh = time.Now()
+		}
+	}
+}
+
+// refreshNodes connects a socket to new nodes and disconnects it from nodes that left.
+// It returns how many nodes it connected to.
+func refreshNodes(socket *zmq4.Socket, connected map[string]bool, discover func() ([]string, error)) int {
+	nodes, err := discover()
+	if err != nil {
+		log.Printf("Failed to refresh nodes: %v\n", err)
+		return 0
+	}
+	added := 0
+	current := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		current[node] = true
+		if connected[node] {
+			continue
+		}
+		if err := socket.Connect(node); err != nil {
+			log.Printf("Failed to connect to node %v: %v\n", node, err)
+			continue
+		}
+		connected[node] = true
+		added++
+		log.Printf("Connected to node: %s", node)
+	}
+	for node := range connected {
+		if !current[node] {
+			socket.Disconnect(node)
+			delete(connected, node)
+		}
+	}
+	return added
+}
+
+// NewCurveKeys generates a keypair. Save it to create a key file.
+func NewCurveKeys() (*CurveKeys, error) {
+	publicKey, secretKey, err := zmq4.NewCurveKeypair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CurveZMQ keypair: %v", err)
+	}
+	return &CurveKeys{PublicKey: publicKey, SecretKey: secretKey}, nil
+}
+
+var (
+	startZAP sync.Once
+	zapErr   error
+)
+
+// secureServer makes socket a CurveZMQ server accepting the authorized client keys. Each server socket
+// has its own ZAP domain, so servers of different services in a process authorize different clients.
+// Must be called before the socket binds.
+func (config CurveConfig) secureServer(socket *zmq4.Socket, domain string) error {
+	keys, err := LoadCurveKeys(config.KeyFile, true)
+	if err != nil {
+		return err
+	}
+	authorized, err := loadAuthorizedKeys(config.AuthorizedKeysFile)
+	if err != nil {
+		return err
+	}
+
+	// the ZAP handler authorizes the connections of all the sockets of the process
+	startZAP.Do(func() { zapErr = zmq4.AuthStart() })
+	if zapErr != nil {
+		return fmt.Errorf("failed to start ZAP handler: %v", zapErr)
+	}
+	zmq4.AuthCurveAdd(domain, authorized...)
+	return socket.ServerAuthCurve(domain, keys.SecretKey)
+}
+
+// secureClient makes socket a CurveZMQ client. Must be called before the socket connects.
+func (keys *CurveClientKeys) secureClient(socket *zmq4.Socket) error {
+	return socket.ClientAuthCurve(keys.ServerPublicKey, keys.PublicKey, keys.SecretKey)
+}
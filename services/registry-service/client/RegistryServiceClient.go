@@ -74,6 +74,54 @@ func (obj *RegistryServiceClient) Discover(serviceName string) ([]string, error)
 	return resp.NodeAddresses, nil
 }
 
+func (obj *RegistryServiceClient) RegisterInstance(serviceName string, instance *pb.ServiceInstance) error {
+	_, err := obj.client.RegisterInstance(context.Background(), &pb.RegisterInstanceRequest{
+		ServiceName: serviceName,
+		Instance:    instance,
+	})
+	if err != nil {
+		return fmt.Errorf("could not call RegisterInstance: %v", err)
+	}
+	return nil
+}
+
+func (obj *RegistryServiceClient) UnregisterInstance(serviceName, instanceID string) error {
+	_, err := obj.client.UnregisterInstance(context.Background(), &pb.UnregisterInstanceRequest{
+		ServiceName: serviceName,
+		InstanceId:  instanceID,
+	})
+	if err != nil {
+		return fmt.Errorf("could not call UnregisterInstance: %v", err)
+	}
+	return nil
+}
+
+func (obj *RegistryServiceClient) DiscoverInstances(serviceName string) ([]*pb.ServiceInstance, error) {
+	resp, err := obj.client.DiscoverInstances(context.Background(), &pb.DiscoverRequest{
+		ServiceName: serviceName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not call DiscoverInstances: %v", err)
+	}
+	return resp.Instances, nil
+}
+
+// DiscoverEndpoints returns the addresses of the service's endpoints for protocol (e.g. pb.ProtocolMQ).
+// Instances without such an endpoint are skipped.
+func (obj *RegistryServiceClient) DiscoverEndpoints(serviceName, protocol string) ([]string, error) {
+	instances, err := obj.DiscoverInstances(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, instance := range instances {
+		if address := instance.GetEndpoint(protocol); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
 func (obj *RegistryServiceClient) IsAlive() (bool, error) {
 	resp, err := obj.client.IsAlive(context.Background(), &emptypb.Empty{})
 	if err != nil {
@@ -2,6 +2,8 @@ package RegistryServiceClient
 
 import (
 	"testing"
+
+	pb "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/common"
 )
 
 func TestRegistryServiceClient(t *testing.T) {
@@ -42,3 +44,39 @@ func TestRegistryServiceClient(t *testing.T) {
 
 	t.Logf("Service is alive: %v", aliveStatus)
 }
+
+func TestRegisterInstance(t *testing.T) {
+	registryClient := NewRegistryServiceClient([]string{"127.0.0.1:8502"})
+
+	serviceName := "InstanceTestService"
+	instance := &pb.ServiceInstance{
+		InstanceId: "127.0.0.1:50052",
+		Endpoints: []*pb.Endpoint{
+			{Protocol: pb.ProtocolGRPC, Address: "127.0.0.1:50052"},
+			{Protocol: pb.ProtocolMQ, Address: "tcp://127.0.0.1:50053"},
+		},
+	}
+	if err := registryClient.RegisterInstance(serviceName, instance); err != nil {
+		t.Fatalf("Failed to register instance: %v", err)
+	}
+
+	mqAddresses, err := registryClient.DiscoverEndpoints(serviceName, pb.ProtocolMQ)
+	if err != nil {
+		t.Fatalf("Failed to discover MQ endpoints: %v", err)
+	}
+	if len(mqAddresses) != 1 || mqAddresses[0] != "tcp://127.0.0.1:50053" {
+		t.Errorf("DiscoverEndpoints(mq) = %v; want [tcp://127.0.0.1:50053]", mqAddresses)
+	}
+
+	// unregistering the instance removes all its endpoints
+	if err := registryClient.UnregisterInstance(serviceName, instance.InstanceId); err != nil {
+		t.Fatalf("Failed to unregister instance: %v", err)
+	}
+	addresses, err := registryClient.Discover(serviceName)
+	if err != nil {
+		t.Fatalf("Failed to discover service addresses: %v", err)
+	}
+	if len(addresses) != 0 {
+		t.Errorf("expected no addresses after UnregisterInstance, got %v", addresses)
+	}
+}
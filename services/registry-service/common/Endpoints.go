@@ -0,0 +1,20 @@
+package RegistryService
+
+// protocols of the endpoints a service instance registers
+const (
+	ProtocolGRPC  = "grpc"
+	ProtocolMQ    = "mq"
+	ProtocolAdmin = "admin"
+	ProtocolHTTP  = "http"
+	ProtocolPub   = "pub" // ZMQ PUB socket of a publisher, see services/common/PubSub.go
+)
+
+// GetEndpoint returns the address of the instance's endpoint for protocol, or "" if it has none.
+func (x *ServiceInstance) GetEndpoint(protocol string) string {
+	for _, endpoint := range x.GetEndpoints() {
+		if endpoint.GetProtocol() == protocol {
+			return endpoint.GetAddress()
+		}
+	}
+	return ""
+}
@@ -21,10 +21,13 @@ import (
 const _ = grpc.SupportPackageIsVersion8
 
 const (
-	RegistryService_Register_FullMethodName   = "/registryservice.RegistryService/Register"
-	RegistryService_Unregister_FullMethodName = "/registryservice.RegistryService/Unregister"
-	RegistryService_Discover_FullMethodName   = "/registryservice.RegistryService/Discover"
-	RegistryService_IsAlive_FullMethodName    = "/registryservice.RegistryService/IsAlive"
+	RegistryService_Register_FullMethodName           = "/registryservice.RegistryService/Register"
+	RegistryService_Unregister_FullMethodName         = "/registryservice.RegistryService/Unregister"
+	RegistryService_Discover_FullMethodName           = "/registryservice.RegistryService/Discover"
+	RegistryService_RegisterInstance_FullMethodName   = "/registryservice.RegistryService/RegisterInstance"
+	RegistryService_UnregisterInstance_FullMethodName = "/registryservice.RegistryService/UnregisterInstance"
+	RegistryService_DiscoverInstances_FullMethodName  = "/registryservice.RegistryService/DiscoverInstances"
+	RegistryService_IsAlive_FullMethodName            = "/registryservice.RegistryService/IsAlive"
 )
 
 // RegistryServiceClient is the client API for RegistryService service.
@@ -39,6 +42,13 @@ type RegistryServiceClient interface {
 	Unregister(ctx context.Context, in *UnregisterRequest, opts ...grpc.CallOption) (*empty.Empty, error)
 	// Discover node addresses for a service
 	Discover(ctx context.Context, in *DiscoverRequest, opts ...grpc.CallOption) (*DiscoverResponse, error)
+	// Register a service instance with all its endpoints. Replaces an instance with the same ID
+	RegisterInstance(ctx context.Context, in *RegisterInstanceRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	// Unregister a service instance with all its endpoints
+	UnregisterInstance(ctx context.Context, in *UnregisterInstanceRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	// Discover the instances of a service and their endpoints. A service that isn't registered has none.
+	// Fails with UNAVAILABLE if the registry can't read its ring, and INTERNAL if the stored instances are corrupt
+	DiscoverInstances(ctx context.Context, in *DiscoverRequest, opts ...grpc.CallOption) (*ServiceInstances, error)
 	// returns true
 	IsAlive(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*wrappers.BoolValue, error)
 }
@@ -81,6 +91,36 @@ func (c *registryServiceClient) Discover(ctx context.Context, in *DiscoverReques
 	return out, nil
 }
 
+func (c *registryServiceClient) RegisterInstance(ctx context.Context, in *RegisterInstanceRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, RegistryService_RegisterInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) UnregisterInstance(ctx context.Context, in *UnregisterInstanceRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, RegistryService_UnregisterInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) DiscoverInstances(ctx context.Context, in *DiscoverRequest, opts ...grpc.CallOption) (*ServiceInstances, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ServiceInstances)
+	err := c.cc.Invoke(ctx, RegistryService_DiscoverInstances_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *registryServiceClient) IsAlive(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*wrappers.BoolValue, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(wrappers.BoolValue)
@@ -103,6 +143,13 @@ type RegistryServiceServer interface {
 	Unregister(context.Context, *UnregisterRequest) (*empty.Empty, error)
 	// Discover node addresses for a service
 	Discover(context.Context, *DiscoverRequest) (*DiscoverResponse, error)
+	// Register a service instance with all its endpoints. Replaces an instance with the same ID
+	RegisterInstance(context.Context, *RegisterInstanceRequest) (*empty.Empty, error)
+	// Unregister a service instance with all its endpoints
+	UnregisterInstance(context.Context, *UnregisterInstanceRequest) (*empty.Empty, error)
+	// Discover the instances of a service and their endpoints. A service that isn't registered has none.
+	// Fails with UNAVAILABLE if the registry can't read its ring, and INTERNAL if the stored instances are corrupt
+	DiscoverInstances(context.Context, *DiscoverRequest) (*ServiceInstances, error)
 	// returns true
 	IsAlive(context.Context, *empty.Empty) (*wrappers.BoolValue, error)
 	mustEmbedUnimplementedRegistryServiceServer()
@@ -121,6 +168,15 @@ func (UnimplementedRegistryServiceServer) Unregister(context.Context, *Unregiste
 func (UnimplementedRegistryServiceServer) Discover(context.Context, *DiscoverRequest) (*DiscoverResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Discover not implemented")
 }
+func (UnimplementedRegistryServiceServer) RegisterInstance(context.Context, *RegisterInstanceRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterInstance not implemented")
+}
+func (UnimplementedRegistryServiceServer) UnregisterInstance(context.Context, *UnregisterInstanceRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnregisterInstance not implemented")
+}
+func (UnimplementedRegistryServiceServer) DiscoverInstances(context.Context, *DiscoverRequest) (*ServiceInstances, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiscoverInstances not implemented")
+}
 func (UnimplementedRegistryServiceServer) IsAlive(context.Context, *empty.Empty) (*wrappers.BoolValue, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method IsAlive not implemented")
 }
@@ -191,6 +247,60 @@ func _RegistryService_Discover_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RegistryService_RegisterInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).RegisterInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_RegisterInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).RegisterInstance(ctx, req.(*RegisterInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_UnregisterInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnregisterInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).UnregisterInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_UnregisterInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).UnregisterInstance(ctx, req.(*UnregisterInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_DiscoverInstances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiscoverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).DiscoverInstances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_DiscoverInstances_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).DiscoverInstances(ctx, req.(*DiscoverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RegistryService_IsAlive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(empty.Empty)
 	if err := dec(in); err != nil {
@@ -228,6 +338,18 @@ var RegistryService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Discover",
 			Handler:    _RegistryService_Discover_Handler,
 		},
+		{
+			MethodName: "RegisterInstance",
+			Handler:    _RegistryService_RegisterInstance_Handler,
+		},
+		{
+			MethodName: "UnregisterInstance",
+			Handler:    _RegistryService_UnregisterInstance_Handler,
+		},
+		{
+			MethodName: "DiscoverInstances",
+			Handler:    _RegistryService_DiscoverInstances_Handler,
+		},
 		{
 			MethodName: "IsAlive",
 			Handler:    _RegistryService_IsAlive_Handler,
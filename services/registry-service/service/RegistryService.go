@@ -11,6 +11,7 @@ import (
 	"time"
 
 	CacheServicePb "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/common"
+	services "github.com/TAULargeScaleWorkshop/AAG/services/common"
 	pb "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/common"
 	dht "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/servant/dht"
 	TestServicePb "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
@@ -20,6 +21,8 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"gopkg.in/yaml.v2"
@@ -29,11 +32,12 @@ var mut sync.Mutex
 var mut2 sync.Mutex
 
 type Config struct {
-	Type                 string `yaml:"type"`
-	Port                 int    `yaml:"port"` // root node port
-	IsAliveCheckInterval int    `yaml:"isAliveCheckInterval"`
-	ChordPort            int    `yaml:"chordPort"`
-	ChordNodeName        string `yaml:"chordNodeName"`
+	Type                 string            `yaml:"type"`
+	Port                 int               `yaml:"port"` // root node port
+	IsAliveCheckInterval int               `yaml:"isAliveCheckInterval"`
+	ChordPort            int               `yaml:"chordPort"`
+	ChordNodeName        string            `yaml:"chordNodeName"`
+	MQ                   services.MQConfig `yaml:"mq"`
 }
 
 func LoadConfig(configFile string) (*Config, error) {
@@ -123,7 +127,24 @@ func startRegistryService(config *Config) error {
 		go server.IsAliveCheck()
 	}
 	pb.RegisterRegistryServiceServer(s, server)
-	log.Printf("RegistryService listening at %v", lis.Addr())
+	startMQ, mqAddress := services.BindMQToService(0, config.MQ, services.NewMQDispatcher(&pb.RegistryService_ServiceDesc, server))
+	go startMQ()
+	// registry nodes are instances of their own service, so clients can discover their MQ endpoints
+	_, err = server.RegisterInstance(context.Background(), &pb.RegisterInstanceRequest{
+		ServiceName: config.Type,
+		Instance: &pb.ServiceInstance{
+			InstanceId: lis.Addr().String(),
+			Endpoints: []*pb.Endpoint{
+				{Protocol: pb.ProtocolGRPC, Address: lis.Addr().String()},
+				{Protocol: pb.ProtocolMQ, Address: mqAddress},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to register the registry node: %v", err)
+		return err
+	}
+	log.Printf("RegistryService listening at %v, MQ at %v", lis.Addr(), mqAddress)
 	if err := s.Serve(lis); err != nil {
 		log.Printf("Failed to serve: %v", err)
 		return err
@@ -131,125 +152,152 @@ func startRegistryService(config *Config) error {
 	return nil
 }
 
-func appendAddress(existingAddresses, newAddress string) string {
-	if existingAddresses == "" {
-		return newAddress
+// loadInstances returns the instances registered for a service, none if it isn't registered. The registry
+// stores them in Chord as the protojson encoding of ServiceInstances. Errors are gRPC errors:
+// Unavailable if Chord can't be read, and Internal if the stored instances can't be parsed.
+func (s *RegistryServiceServer) loadInstances(serviceName string) (*pb.ServiceInstances, error) {
+	instances := &pb.ServiceInstances{}
+	registered, err := s.containsService(serviceName)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to read services: %v", err)
+	}
+	if !registered {
+		return instances, nil
+	}
+	serialized, err := s.Chord.Get(serviceName)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to read instances of %s: %v", serviceName, err)
+	}
+	if serialized == "" {
+		return instances, nil
+	}
+	err = protojson.Unmarshal([]byte(serialized), instances)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to parse instances of %s: %v", serviceName, err)
 	}
-	return existingAddresses + "," + newAddress
+	return instances, nil
 }
 
-func removeAddress(addressList, addressToRemove string) string {
-	addresses := strings.Split(addressList, ",")
-	filteredAddresses := []string{}
-	for _, addr := range addresses {
-		if addr != addressToRemove {
-			filteredAddresses = append(filteredAddresses, addr)
+// storeInstances replaces the instances registered for a service. A service without instances is deleted.
+func (s *RegistryServiceServer) storeInstances(serviceName string, instances *pb.ServiceInstances) error {
+	if len(instances.Instances) == 0 {
+		return s.Chord.Delete(serviceName)
+	}
+	serialized, err := protojson.Marshal(instances)
+	if err != nil {
+		return err
+	}
+	return s.Chord.Set(serviceName, string(serialized))
+}
+
+func removeInstance(instances *pb.ServiceInstances, instanceID string) bool {
+	for i, instance := range instances.Instances {
+		if instance.InstanceId == instanceID {
+			instances.Instances = append(instances.Instances[:i], instances.Instances[i+1:]...)
+			return true
 		}
 	}
-	return strings.Join(filteredAddresses, ",")
+	return false
 }
 
-func (s *RegistryServiceServer) Register(ctx context.Context, req *pb.RegisterRequest) (*emptypb.Empty, error) {
+func (s *RegistryServiceServer) RegisterInstance(ctx context.Context, req *pb.RegisterInstanceRequest) (*emptypb.Empty, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	serviceName := req.GetServiceName()
-	nodeAddress := req.GetNodeAddress()
+	instance := req.GetInstance()
+	if instance.GetInstanceId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "instance of %s has no ID", serviceName)
+	}
 
-	// Retrieve all keys from the DHT
-	servicesList, err := s.Chord.GetAllKeys()
+	instances, err := s.loadInstances(serviceName)
 	if err != nil {
-		log.Printf("Error retrieving all services: %v\n", err)
+		log.Printf("Error retrieving instances of %s: %v\n", serviceName, err)
 		return nil, err
 	}
+	removeInstance(instances, instance.InstanceId)
+	instances.Instances = append(instances.Instances, instance)
 
-	var existingAddresses string
-
-	// Check if the service already exists
-	for _, service := range servicesList {
-		if service == serviceName {
-			existingAddresses, err = s.Chord.Get(serviceName)
-			if err != nil {
-				log.Printf("Error retrieving existing addresses for %s: %v\n", serviceName, err)
-				return nil, err
-			}
-			break
-		}
-	}
-
-	// Append the new address to the existing list
-	addressList := appendAddress(existingAddresses, nodeAddress)
-
-	err = s.Chord.Set(serviceName, addressList)
+	err = s.storeInstances(serviceName, instances)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Registered %s at %s\n", serviceName, nodeAddress)
+	log.Printf("Registered %s instance %s with endpoints %v\n", serviceName, instance.InstanceId, instance.Endpoints)
 	return &emptypb.Empty{}, nil
 }
 
-func (s *RegistryServiceServer) Unregister(ctx context.Context, req *pb.UnregisterRequest) (*emptypb.Empty, error) {
+func (s *RegistryServiceServer) UnregisterInstance(ctx context.Context, req *pb.UnregisterInstanceRequest) (*emptypb.Empty, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	serviceName := req.GetServiceName()
-	nodeAddress := req.GetNodeAddress()
+	instanceID := req.GetInstanceId()
 
-	if !s.ContainsService(serviceName) {
-		log.Printf("Service already deleted")
-		return nil, nil
-	}
-	// Retrieve the current list of addresses for the service
-	existingAddresses, err := s.Chord.Get(serviceName)
+	instances, err := s.loadInstances(serviceName)
 	if err != nil {
-		log.Printf("Failed to get addresses for service %s: %v\n", serviceName, err)
+		log.Printf("Failed to get instances of service %s: %v\n", serviceName, err)
 		return nil, err
 	}
-
-	// Remove the specific node address from the list
-	updatedAddresses := removeAddress(existingAddresses, nodeAddress)
-
-	// If no addresses are left, delete the service entry completely
-	if updatedAddresses == "" {
-		err = s.Chord.Delete(serviceName)
-		if err != nil {
-			log.Printf("Failed to delete service entry for %s: %v\n", serviceName, err)
-			return nil, err
-		}
-		log.Printf("Unregistered %s from %s\n", serviceName, nodeAddress)
-	} else {
-		// Update the registry with the modified list of addresses
-		err = s.Chord.Set(serviceName, updatedAddresses)
-		if err != nil {
-			log.Printf("Failed to update addresses for %s: %v\n", serviceName, err)
-			return nil, err
-		}
-		log.Printf("Updated addresses for %s after unregistration: %v\n", serviceName, updatedAddresses)
+	if !removeInstance(instances, instanceID) {
+		log.Printf("Instance %s of %s already deleted", instanceID, serviceName)
+		return &emptypb.Empty{}, nil
 	}
 
+	err = s.storeInstances(serviceName, instances)
+	if err != nil {
+		log.Printf("Failed to update instances of %s: %v\n", serviceName, err)
+		return nil, err
+	}
+	log.Printf("Unregistered %s instance %s\n", serviceName, instanceID)
 	return &emptypb.Empty{}, nil
 }
 
-func (s *RegistryServiceServer) Discover(ctx context.Context, req *pb.DiscoverRequest) (*pb.DiscoverResponse, error) {
+func (s *RegistryServiceServer) DiscoverInstances(ctx context.Context, req *pb.DiscoverRequest) (*pb.ServiceInstances, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	serviceName := req.GetServiceName()
 
-	if !s.ContainsService(serviceName) {
-		log.Printf("Service already deleted")
-		return nil, nil
+	instances, err := s.loadInstances(req.GetServiceName())
+	if err != nil {
+		log.Printf("Failed to get instances of service %s: %v\n", req.GetServiceName(), err)
+		return nil, err
 	}
-	// Get the serialized address list
-	serializedAddresses, err := s.Chord.Get(serviceName)
+	return instances, nil
+}
+
+// Register registers a node with a single gRPC endpoint, identified by its address.
+func (s *RegistryServiceServer) Register(ctx context.Context, req *pb.RegisterRequest) (*emptypb.Empty, error) {
+	return s.RegisterInstance(ctx, &pb.RegisterInstanceRequest{
+		ServiceName: req.GetServiceName(),
+		Instance: &pb.ServiceInstance{
+			InstanceId: req.GetNodeAddress(),
+			Endpoints:  []*pb.Endpoint{{Protocol: pb.ProtocolGRPC, Address: req.GetNodeAddress()}},
+		},
+	})
+}
+
+func (s *RegistryServiceServer) Unregister(ctx context.Context, req *pb.UnregisterRequest) (*emptypb.Empty, error) {
+	return s.UnregisterInstance(ctx, &pb.UnregisterInstanceRequest{
+		ServiceName: req.GetServiceName(),
+		InstanceId:  req.GetNodeAddress(),
+	})
+}
+
+// Discover returns the gRPC addresses of a service's instances.
+func (s *RegistryServiceServer) Discover(ctx context.Context, req *pb.DiscoverRequest) (*pb.DiscoverResponse, error) {
+	instances, err := s.DiscoverInstances(ctx, req)
 	if err != nil {
-		return nil, grpc.Errorf(codes.NotFound, "Service not found")
+		return nil, err
 	}
 
-	// Deserialize the address list
-	nodeAddresses := strings.Split(serializedAddresses, ",")
+	var nodeAddresses []string
+	for _, instance := range instances.Instances {
+		if address := instance.GetEndpoint(pb.ProtocolGRPC); address != "" {
+			nodeAddresses = append(nodeAddresses, address)
+		}
+	}
 
-	log.Printf("Discovered addresses for %s: %v\n", serviceName, nodeAddresses)
+	log.Printf("Discovered addresses for %s: %v\n", req.GetServiceName(), nodeAddresses)
 	return &pb.DiscoverResponse{NodeAddresses: nodeAddresses}, nil
 }
 
@@ -267,30 +315,29 @@ func (s *RegistryServiceServer) IsAliveCheck() {
 		log.Printf("GetAllKeys returned servicesList: %v", servicesList)
 
 		for _, serviceName := range servicesList {
-			nodeAddresses, err := s.Chord.Get(serviceName)
+			instances, err := s.loadInstances(serviceName)
 			if err != nil {
-				log.Printf("Failed to get node address for service %v: %v", serviceName, err)
-				return
+				log.Printf("Failed to get instances of service %v: %v", serviceName, err)
+				continue
 			}
-			if nodeAddresses == "" {
-				log.Printf("No address found for service: %s", serviceName)
-				return
-			}
-			// Check each address for health
-			addresses := strings.Split(nodeAddresses, ",")
-			for _, nodeAddress := range addresses {
+			// Check each instance for health through its gRPC endpoint
+			for _, instance := range instances.Instances {
+				nodeAddress := instance.GetEndpoint(pb.ProtocolGRPC)
+				if nodeAddress == "" {
+					continue
+				}
 				log.Printf("Checking health of %s at %s\n", serviceName, nodeAddress)
-				go s.checkNodeHealth(serviceName, nodeAddress)
+				go s.checkNodeHealth(serviceName, instance.InstanceId, nodeAddress)
 			}
 		}
 	}
 }
 
-func (s *RegistryServiceServer) checkNodeHealth(serviceName, nodeAddress string) {
+func (s *RegistryServiceServer) checkNodeHealth(serviceName, instanceID, nodeAddress string) {
 	conn, err := grpc.Dial(nodeAddress, grpc.WithInsecure())
 	if err != nil {
 		log.Printf("Connection failed to %s: %v\n", nodeAddress, err)
-		s.handleFailure(serviceName, nodeAddress)
+		s.handleFailure(serviceName, instanceID)
 		return
 	}
 	defer conn.Close()
@@ -307,6 +354,9 @@ func (s *RegistryServiceServer) checkNodeHealth(serviceName, nodeAddress string)
 	case "CacheService":
 		client := CacheServicePb.NewCacheServiceClient(conn)
 		isAliveResponse = client.IsAlive
+	case "RegistryService":
+		client := pb.NewRegistryServiceClient(conn)
+		isAliveResponse = client.IsAlive
 	default:
 		return
 	}
@@ -321,81 +371,47 @@ func (s *RegistryServiceServer) checkNodeHealth(serviceName, nodeAddress string)
 		_, err := isAliveResponse(ctx, &emptypb.Empty{})
 		if err != nil {
 			log.Printf("IsAlive check failed again for %s at %s: %v\n", serviceName, nodeAddress, err)
-			s.handleFailure(serviceName, nodeAddress)
+			s.handleFailure(serviceName, instanceID)
 		}
 	} else {
 		s.handleSuccess(serviceName, nodeAddress)
 	}
 }
 
-func (s *RegistryServiceServer) handleFailure(serviceName, nodeAddress string) {
-	log.Printf("handleFailure: %v, %v", serviceName, nodeAddress)
-	var err error
-	if serviceName == "TestService" {
-		// Handle removal of the TestServiceMQ
-		s.handleMQFailure(nodeAddress)
-	}
-
-	_, err = s.Unregister(context.Background(), &pb.UnregisterRequest{
+// handleFailure unregisters a failed instance, which removes all its endpoints together.
+func (s *RegistryServiceServer) handleFailure(serviceName, instanceID string) {
+	log.Printf("handleFailure: %v, %v", serviceName, instanceID)
+	_, err := s.UnregisterInstance(context.Background(), &pb.UnregisterInstanceRequest{
 		ServiceName: serviceName,
-		NodeAddress: nodeAddress,
+		InstanceId:  instanceID,
 	})
 
 	if err != nil {
-		log.Printf("Failed to unregister failed node %s for service %s: %v\n", nodeAddress, serviceName, err)
+		log.Printf("Failed to unregister failed instance %s of service %s: %v\n", instanceID, serviceName, err)
 		return
 	}
-	log.Printf("Unregistered failed node %s for service %s\n", nodeAddress, serviceName)
+	log.Printf("Unregistered failed instance %s of service %s\n", instanceID, serviceName)
 }
 
-func (s *RegistryServiceServer) handleMQFailure(nodeAddress string) {
-	mqServiceName := "TestServiceMQ"
-	if !s.ContainsService(mqServiceName) {
-		log.Printf("Service already deleted")
-		return
-	}
-	mqAddresses, err := s.Chord.Get(mqServiceName)
-	if err != nil {
-		log.Printf("Failed to get MQ addresses for %s: %v\n", mqServiceName, err)
-		return
-	}
-	mqAddress := GetMQAddress(mqAddresses, nodeAddress)
-	_, err = s.Unregister(context.Background(), &pb.UnregisterRequest{
-		ServiceName: mqServiceName,
-		NodeAddress: mqAddress,
-	})
-
+func (s *RegistryServiceServer) ContainsService(serviceName string) bool {
+	registered, err := s.containsService(serviceName)
 	if err != nil {
-		log.Printf("Failed to unregister failed node %s for service %s: %v\n", mqAddress, mqServiceName, err)
-		return
-	}
-	log.Printf("Deleted failed MQ address for node %s from service %s\n", mqAddress, mqServiceName)
-}
-
-func GetMQAddress(mqAddresses string, nodeAddress string) string {
-	addresses := strings.Split(mqAddresses, ",")
-	for _, addr := range addresses {
-		// Extract the TestService address from MQwithTestAddress
-		parts := strings.Split(addr, "@")
-		if len(parts) == 2 && parts[1] == nodeAddress {
-			return strings.Join(parts, "@")
-		}
+		log.Printf("Failed to get service keys from Chord: %v", err)
 	}
-	return ""
+	return registered
 }
 
-func (s *RegistryServiceServer) ContainsService(serviceName string) bool {
+func (s *RegistryServiceServer) containsService(serviceName string) (bool, error) {
 	servicesList, err := s.Chord.GetAllKeys()
 	if err != nil {
-		log.Printf("Failed to get service keys from Chord: %v", err)
-		return false
+		return false, err
 	}
 	for _, service := range servicesList {
-		if strings.Contains(service, serviceName) {
-			return true
+		if service == serviceName {
+			return true, nil
 		}
 	}
-	return false
+	return false, nil
 }
 
 func (s *RegistryServiceServer) handleSuccess(serviceName, nodeAddress string) {
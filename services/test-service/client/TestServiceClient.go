@@ -8,7 +8,8 @@ import (
 
 	RegistryServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/client"
 	service "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -22,6 +23,7 @@ type TestServiceClient struct {
 func NewTestServiceClient(address []string, registryClient *RegistryServiceClient.RegistryServiceClient) *TestServiceClient {
 	return &TestServiceClient{
 		ServiceClientBase: services.ServiceClientBase[service.TestServiceClient]{
+			ServiceName:       serviceName,
 			RegistryAddresses: address,
 			CreateClient:      service.NewTestServiceClient,
 			RegistryClient:    registryClient,
@@ -138,86 +140,117 @@ func (obj *TestServiceClient) ExtractLinksFromURL(url string, depth int32) ([]st
 }
 
 func (obj *TestServiceClient) HelloWorldAsync() (func() (string, error), error) {
-	mqsocket, err := obj.ConnectMQ()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MQ: %w", err)
-	}
-
-	msg, err := services.NewMarshaledCallParameter("HelloWorld", &emptypb.Empty{})
-	if err != nil {
-		mqsocket.Close()
-		return nil, fmt.Errorf("failed to marshal call parameters: %w", err)
-	}
-	_, err = mqsocket.SendBytes(msg, 0)
-	if err != nil {
-		mqsocket.Close()
-		return nil, fmt.Errorf("failed to send message: %w", err)
-	}
-
+	f := services.CallAsync[*emptypb.Empty, *wrapperspb.StringValue](context.Background(), obj, "HelloWorld", &emptypb.Empty{})
 	ret := func() (string, error) {
-		defer mqsocket.Close()
-		rv, err := mqsocket.RecvBytes(0)
-		if err != nil {
-			return "", fmt.Errorf("failed to receive response: %w", err)
-		}
-		str := &wrapperspb.StringValue{}
-		err = proto.Unmarshal(rv, str)
+		str, err := f.Get()
 		if err != nil {
-			return "", fmt.Errorf("failed to unmarshal string value: %w", err)
+			return "", err
 		}
 		return str.Value, nil
 	}
-
 	return ret, nil
 }
 
 func (obj *TestServiceClient) ExtractLinksFromURLAsync(url string, depth int32) (func() ([]string, error), error) {
-	// Step 1: Connect to the MQ
-	mqsocket, err := obj.ConnectMQ()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MQ: %w", err)
-	}
-
-	// Step 2: Prepare request parameters
 	req := &service.ExtractLinksFromURLParameters{
 		Url:   url,
 		Depth: depth,
 	}
-
-	// Step 3: Marshal the call parameters
-	msg, err := services.NewMarshaledCallParameter("ExtractLinksFromURL", req)
-	if err != nil {
-		mqsocket.Close()
-		return nil, fmt.Errorf("failed to marshal call parameters: %w", err)
+	f := services.CallAsync[*service.ExtractLinksFromURLParameters, *service.ExtractLinksFromURLReturnedValue](context.Background(), obj, "ExtractLinksFromURL", req)
+	ret := func() ([]string, error) {
+		resp, err := f.Get()
+		if err != nil {
+			return nil, err
+		}
+		return resp.Links, nil
 	}
+	return ret, nil
+}
+
+// ExtractLinksFromURLOneWay starts a crawl of url without waiting for it. Subscribe to
+// service.CrawlCompletedTopic to learn when it completed.
+func (obj *TestServiceClient) ExtractLinksFromURLOneWay(url string, depth int32) error {
+	req := &service.ExtractLinksFromURLParameters{Url: url, Depth: depth}
+	return services.CallOneWay(context.Background(), obj, "ExtractLinksFromURL", req)
+}
 
-	// Step 4: Send the marshaled message
-	_, err = mqsocket.SendBytes(msg, 0)
+// StoreBatch stores many key/value pairs in a single MQ round trip. The pairs are stored concurrently.
+func (obj *TestServiceClient) StoreBatch(values map[string]string) error {
+	ctx := context.Background()
+	var batch services.Batch
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		if _, err := batch.Add(ctx, "Store", &service.StoreKeyValue{Key: key, Value: value}); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	replies, err := services.CallBatch(ctx, obj, &batch).Get()
 	if err != nil {
-		mqsocket.Close()
-		return nil, fmt.Errorf("failed to send message: %w", err)
+		return fmt.Errorf("could not call Store batch: %v", err)
+	}
+	for i, reply := range replies {
+		if _, err := services.BatchResult[*emptypb.Empty](reply); err != nil {
+			return fmt.Errorf("could not store key '%s': %v", keys[i], err)
+		}
 	}
+	return nil
+}
 
-	// Step 5: Return a function to receive the response asynchronously
-	ret := func() ([]string, error) {
-		defer mqsocket.Close()
+// HelloWorldDurable sends a durable HelloWorld call and returns its job ID once a node persisted it.
+func (obj *TestServiceClient) HelloWorldDurable() (string, error) {
+	return services.CallDurable(context.Background(), obj, "HelloWorld", &emptypb.Empty{}).Get()
+}
 
-		// Step 6: Receive the response
-		rv, err := mqsocket.RecvBytes(0)
-		if err != nil {
-			return nil, fmt.Errorf("failed to receive response: %w", err)
+// ListDeadLetters returns the dead-letter queues of all the nodes, each dead letter with the node holding it.
+// Nodes without durable async calls are skipped. It fails with codes.FailedPrecondition if no node has them.
+func (obj *TestServiceClient) ListDeadLetters() ([]*service.DeadLetter, error) {
+	nodes, err := obj.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	var deadLetters []*service.DeadLetter
+	durable := false
+	for _, node := range nodes {
+		r, err := obj.listDeadLetters(node)
+		if status.Code(err) == codes.FailedPrecondition {
+			continue
 		}
-
-		// Step 7: Unmarshal the response
-		resp := &service.ExtractLinksFromURLReturnedValue{}
-		err = proto.Unmarshal(rv, resp)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+			return nil, fmt.Errorf("could not call ListDeadLetters on %v: %v", node, err)
 		}
+		durable = true
+		for _, deadLetter := range r.DeadLetters {
+			deadLetter.Node = node
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+	if !durable {
+		return nil, status.Errorf(codes.FailedPrecondition, "durable async calls are disabled on all %v nodes", len(nodes))
+	}
+	return deadLetters, nil
+}
 
-		// Step 8: Return the extracted links
-		return resp.Links, nil
+func (obj *TestServiceClient) listDeadLetters(node string) (*service.DeadLetters, error) {
+	c, closeFunc, err := obj.ConnectTo(node)
+	if err != nil {
+		return nil, err
 	}
+	defer closeFunc()
+	return c.ListDeadLetters(context.Background(), &emptypb.Empty{})
+}
 
-	return ret, nil
+// ReplayDeadLetter retries a dead letter on the node holding it, the Node of its DeadLetter.
+func (obj *TestServiceClient) ReplayDeadLetter(deadLetter *service.DeadLetter) error {
+	c, closeFunc, err := obj.ConnectTo(deadLetter.Node)
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	_, err = c.ReplayDeadLetter(context.Background(), wrapperspb.String(deadLetter.JobId))
+	if err != nil {
+		return fmt.Errorf("could not call ReplayDeadLetter: %v", err)
+	}
+	return nil
 }
@@ -1,10 +1,21 @@
 package TestService
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"testing"
+	"time"
 
+	services "github.com/TAULargeScaleWorkshop/AAG/services/common"
 	RegistryServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/client"
+	service "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func startTestService() ([]string, *RegistryServiceClient.RegistryServiceClient) {
@@ -222,3 +233,212 @@ func TestExtractLinksFromURLAsync(t *testing.T) {
 
 	t.Logf("Async Extracted Links seccessfully")
 }
+
+func TestCallAsyncHelloToUser(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+	username := "AAG"
+
+	// HelloToUser has no hand-written async wrapper
+	f := services.CallAsync[*wrapperspb.StringValue, *wrapperspb.StringValue](context.Background(), c, "HelloToUser", wrapperspb.String(username))
+	r, err := f.Get()
+	if err != nil {
+		t.Fatalf("CallAsync HelloToUser returned error: %v", err)
+	}
+
+	expected := "Hello " + username
+	if r.Value != expected {
+		t.Errorf("HelloToUser(%s) = %s; want %s", username, r.Value, expected)
+	}
+}
+
+func TestCallAsyncCancel(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+	req := &service.ExtractLinksFromURLParameters{Url: "http://example.com", Depth: 3}
+
+	f := services.CallAsync[*service.ExtractLinksFromURLParameters, *service.ExtractLinksFromURLReturnedValue](context.Background(), c, "ExtractLinksFromURL", req)
+	f.Cancel()
+	_, err := f.Get()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled after Cancel, got: %v", err)
+	}
+}
+
+func TestCallAsyncUnknownMethod(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+
+	f := services.CallAsync[*emptypb.Empty, *emptypb.Empty](context.Background(), c, "NoSuchMethod", &emptypb.Empty{})
+	_, err := f.Get()
+	var callErr *services.CallError
+	if !errors.As(err, &callErr) || callErr.Code != services.ErrorCode_METHOD_NOT_FOUND {
+		t.Errorf("expected METHOD_NOT_FOUND error, got: %v", err)
+	}
+}
+
+func TestCallStreamWaitAndRand(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+
+	stream, err := services.CallStream[*wrapperspb.Int32Value, *wrapperspb.Int32Value](context.Background(), c, "WaitAndRand", wrapperspb.Int32(3))
+	if err != nil {
+		t.Fatalf("CallStream WaitAndRand failed: %v", err)
+	}
+	var res []*wrapperspb.Int32Value
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("WaitAndRand stream returned error: %v", err)
+		}
+		res = append(res, msg)
+	}
+	if len(res) == 0 {
+		t.Fatalf("WaitAndRand streamed no messages")
+	}
+	t.Logf("Returned random number: %v\n", res[len(res)-1].Value)
+}
+
+func TestStoreBatch(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+	values := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		values[fmt.Sprintf("batch-key%d", i)] = fmt.Sprintf("value%d", i)
+	}
+
+	err := c.StoreBatch(values)
+	if err != nil {
+		t.Fatalf("StoreBatch returned error: %v", err)
+	}
+	for _, key := range []string{"batch-key0", "batch-key42", "batch-key99"} {
+		value, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("could not get value for key '%s': %v", key, err)
+		}
+		if value != values[key] {
+			t.Errorf("unexpected value for key '%s', got: %s, want: %s", key, value, values[key])
+		}
+	}
+}
+
+func TestHelloWorldDurable(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+	if _, err := c.ListDeadLetters(); status.Code(err) == codes.FailedPrecondition {
+		t.Skip("durable async calls are disabled, see durable in TestService.yaml")
+	}
+	jobID, err := c.HelloWorldDurable()
+	if err != nil {
+		t.Fatalf("HelloWorldDurable returned error: %v", err)
+	}
+	if jobID == "" {
+		t.Fatalf("HelloWorldDurable returned no job ID")
+	}
+
+	// a durable call of a missing method fails permanently and lands in the dead-letter queue of the node running it
+	deadJobID, err := services.CallDurable(context.Background(), c, "NoSuchMethod", &emptypb.Empty{}).Get()
+	if err != nil {
+		t.Fatalf("CallDurable returned error: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		deadLetters, err := c.ListDeadLetters()
+		if err != nil {
+			t.Fatalf("ListDeadLetters returned error: %v", err)
+		}
+		var found *service.DeadLetter
+		for _, deadLetter := range deadLetters {
+			if deadLetter.JobId == deadJobID {
+				found = deadLetter
+			}
+		}
+		if found != nil {
+			// permanent errors aren't retried
+			if found.Method != "NoSuchMethod" || found.Attempts != 1 || found.Node == "" {
+				t.Errorf("dead letter %v, want NoSuchMethod after 1 attempt with its node", found)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %v didn't reach a dead-letter queue", deadJobID)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestCrawlCompletedEvent(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+
+	subscriber, err := c.NewSubscriber()
+	if err != nil {
+		t.Fatalf("NewSubscriber failed: %v", err)
+	}
+	defer subscriber.Close()
+	events := make(chan *service.CrawlCompleted, 1)
+	err = services.OnEvent(subscriber, "crawl.", func(topic string, event *service.CrawlCompleted) {
+		// later events are dropped, so the subscriber never blocks
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("OnEvent failed: %v", err)
+	}
+	// give the subscription time to reach the publishers
+	time.Sleep(time.Second)
+
+	url := "http://example.com"
+	if _, err := c.ExtractLinksFromURL(url, 1); err != nil {
+		t.Fatalf("could not call ExtractLinksFromURL: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.Url != url {
+			t.Errorf("crawl completed event for %v; want %v", event.Url, url)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("no crawl completed event received")
+	}
+}
+
+func TestExtractLinksFromURLOneWay(t *testing.T) {
+	addresses, registryClient := startTestService()
+	c := NewTestServiceClient(addresses, registryClient)
+
+	// one-way calls get no reply, so the crawl's completion is observed through its event
+	subscriber, err := c.NewSubscriber()
+	if err != nil {
+		t.Fatalf("NewSubscriber failed: %v", err)
+	}
+	defer subscriber.Close()
+	url := "http://example.org"
+	events := make(chan *service.CrawlCompleted, 1)
+	err = services.OnEvent(subscriber, service.CrawlCompletedTopic, func(topic string, event *service.CrawlCompleted) {
+		if event.Url != url {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("OnEvent failed: %v", err)
+	}
+	time.Sleep(time.Second)
+
+	if err := c.ExtractLinksFromURLOneWay(url, 1); err != nil {
+		t.Fatalf("could not call ExtractLinksFromURLOneWay: %v", err)
+	}
+	select {
+	case <-events:
+	case <-time.After(10 * time.Second):
+		t.Errorf("one-way ExtractLinksFromURL didn't complete")
+	}
+}
@@ -28,6 +28,8 @@ const (
 	TestService_WaitAndRand_FullMethodName         = "/testservice.TestService/WaitAndRand"
 	TestService_ExtractLinksFromURL_FullMethodName = "/testservice.TestService/ExtractLinksFromURL"
 	TestService_IsAlive_FullMethodName             = "/testservice.TestService/IsAlive"
+	TestService_ListDeadLetters_FullMethodName     = "/testservice.TestService/ListDeadLetters"
+	TestService_ReplayDeadLetter_FullMethodName    = "/testservice.TestService/ReplayDeadLetter"
 )
 
 // TestServiceClient is the client API for TestService service.
@@ -49,6 +51,10 @@ type TestServiceClient interface {
 	ExtractLinksFromURL(ctx context.Context, in *ExtractLinksFromURLParameters, opts ...grpc.CallOption) (*ExtractLinksFromURLReturnedValue, error)
 	// returns true
 	IsAlive(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*wrappers.BoolValue, error)
+	// returns the dead-letter queue of durable async calls of the node
+	ListDeadLetters(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*DeadLetters, error)
+	// moves a dead letter of the node back to its job queue, by job ID
+	ReplayDeadLetter(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*empty.Empty, error)
 }
 
 type testServiceClient struct {
@@ -145,6 +151,24 @@ func (c *testServiceClient) IsAlive(ctx context.Context, in *empty.Empty, opts .
 	return out, nil
 }
 
+func (c *testServiceClient) ListDeadLetters(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*DeadLetters, error) {
+	out := new(DeadLetters)
+	err := c.cc.Invoke(ctx, TestService_ListDeadLetters_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *testServiceClient) ReplayDeadLetter(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, TestService_ReplayDeadLetter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TestServiceServer is the server API for TestService service.
 // All implementations must embed UnimplementedTestServiceServer
 // for forward compatibility
@@ -164,6 +188,10 @@ type TestServiceServer interface {
 	ExtractLinksFromURL(context.Context, *ExtractLinksFromURLParameters) (*ExtractLinksFromURLReturnedValue, error)
 	// returns true
 	IsAlive(context.Context, *empty.Empty) (*wrappers.BoolValue, error)
+	// returns the dead-letter queue of durable async calls of the node
+	ListDeadLetters(context.Context, *empty.Empty) (*DeadLetters, error)
+	// moves a dead letter of the node back to its job queue, by job ID
+	ReplayDeadLetter(context.Context, *wrappers.StringValue) (*empty.Empty, error)
 	mustEmbedUnimplementedTestServiceServer()
 }
 
@@ -192,6 +220,12 @@ func (UnimplementedTestServiceServer) ExtractLinksFromURL(context.Context, *Extr
 func (UnimplementedTestServiceServer) IsAlive(context.Context, *empty.Empty) (*wrappers.BoolValue, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method IsAlive not implemented")
 }
+func (UnimplementedTestServiceServer) ListDeadLetters(context.Context, *empty.Empty) (*DeadLetters, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetters not implemented")
+}
+func (UnimplementedTestServiceServer) ReplayDeadLetter(context.Context, *wrappers.StringValue) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayDeadLetter not implemented")
+}
 func (UnimplementedTestServiceServer) mustEmbedUnimplementedTestServiceServer() {}
 
 // UnsafeTestServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -334,6 +368,42 @@ func _TestService_IsAlive_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TestService_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TestServiceServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TestService_ListDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TestServiceServer).ListDeadLetters(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TestService_ReplayDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrappers.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TestServiceServer).ReplayDeadLetter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TestService_ReplayDeadLetter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TestServiceServer).ReplayDeadLetter(ctx, req.(*wrappers.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TestService_ServiceDesc is the grpc.ServiceDesc for TestService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -365,6 +435,14 @@ var TestService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "IsAlive",
 			Handler:    _TestService_IsAlive_Handler,
 		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _TestService_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetter",
+			Handler:    _TestService_ReplayDeadLetter_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
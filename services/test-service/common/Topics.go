@@ -0,0 +1,7 @@
+package TestService
+
+// topics TestService publishes events on
+const (
+	// CrawlCompleted events
+	CrawlCompletedTopic = "crawl.completed"
+)
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"reflect"
 	"strconv"
 	"strings"
 
 	CacheServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/cache-service/client" //
 	RegistryServiceClient "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/client"
+	RegistryServicePb "github.com/TAULargeScaleWorkshop/AAG/services/registry-service/common"
 
 	services "github.com/TAULargeScaleWorkshop/AAG/services/common"
 	pb "github.com/TAULargeScaleWorkshop/AAG/services/test-service/common"
@@ -17,22 +17,27 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var serviceInstance *testServiceImplementation
-
 type Config struct {
-	Type            string `yaml:"type"`
-	RegistryAddress string `yaml:"registryAddress"`
-	RegNum          int    `yaml:"regNum"`
+	Type            string            `yaml:"type"`
+	RegistryAddress string            `yaml:"registryAddress"`
+	RegNum          int               `yaml:"regNum"`
+	MQ              services.MQConfig `yaml:"mq"`
+
+	Durable   services.JobQueueConfig            `yaml:"durable"`
+	NearCache CacheServiceClient.NearCacheConfig `yaml:"nearCache"`
 }
 
 type testServiceImplementation struct {
 	pb.UnimplementedTestServiceServer
 	CacheClient *CacheServiceClient.CacheServiceClient
+	JobQueue    *services.JobQueue // nil unless durable async calls are enabled
+	Publisher   *services.Publisher
 }
 
 func loadConfigFromData(configData []byte) (*Config, error) {
@@ -89,7 +94,13 @@ func Start(configData []byte) string {
 	}
 
 	testServiceImp := ConnectCacheService(registryAddresses, registryClient)
-	serviceInstance = testServiceImp
+	testServiceImp.CacheClient.Curve = config.MQ.Curve
+	if config.NearCache.Enabled {
+		if err := testServiceImp.CacheClient.EnableNearCache(config.NearCache); err != nil {
+			log.Printf("Failed to enable the near cache: %v", err)
+			return ""
+		}
+	}
 	bindgRPCToService := func(s grpc.ServiceRegistrar) {
 		pb.RegisterTestServiceServer(s, testServiceImp)
 	}
@@ -98,21 +109,35 @@ func Start(configData []byte) string {
 
 	newAddress := services.Start(serviceName, 0, bindgRPCToService)
 	// MQ setup
-	startMQ, mqAddress := services.BindMQToService(0, messageHandler)
-	MQwithTestAddress := mqAddress + "@" + newAddress
-
-	unregister := services.RegisterAddress(serviceName, registryAddresses, newAddress)
+	messageHandler := services.NewMQDispatcher(&pb.TestService_ServiceDesc, testServiceImp)
+	if config.Durable.Enabled {
+		// durable jobs are sent to the MQ nodes of the service, including this one
+		mqClient := services.NewServiceClientBase(serviceName, registryClient, registryAddresses, pb.NewTestServiceClient)
+		mqClient.Curve = config.MQ.Curve
+		testServiceImp.JobQueue, err = services.NewJobQueue(config.Durable, mqClient)
+		if err != nil {
+			log.Printf("Failed to open job queue: %v", err)
+			return ""
+		}
+		messageHandler = testServiceImp.JobQueue.Handler(messageHandler)
+	}
+	startMQ, mqAddress := services.BindMQToService(0, config.MQ, messageHandler)
+	var pubAddress string
+	testServiceImp.Publisher, pubAddress = services.NewPublisher(0)
+
+	unregister := services.RegisterInstance(serviceName, registryAddresses, map[string]string{
+		RegistryServicePb.ProtocolGRPC: newAddress,
+		RegistryServicePb.ProtocolMQ:   mqAddress,
+		RegistryServicePb.ProtocolPub:  pubAddress,
+	})
 
 	if unregister == nil {
 		log.Fatalf("Failed to register the service\n")
 	}
 
 	go startMQ()
-
-	// Register MQ address
-	registerMQAddress := services.RegisterAddress(serviceName+"MQ", registryAddresses, MQwithTestAddress)
-	if registerMQAddress == nil {
-		log.Fatalf("Failed to register MQ address")
+	if testServiceImp.JobQueue != nil {
+		testServiceImp.JobQueue.Start()
 	}
 
 	return newAddress
@@ -165,51 +190,35 @@ func (obj *testServiceImplementation) ExtractLinksFromURL(ctx context.Context, r
 		return nil, err
 	}
 
+	event := &pb.CrawlCompleted{Url: req.Url, Depth: req.Depth, Links: int32(len(links))}
+	if err := obj.Publisher.Publish(pb.CrawlCompletedTopic, event); err != nil {
+		log.Printf("Failed to publish crawl completion: %v", err)
+	}
 	return &pb.ExtractLinksFromURLReturnedValue{Links: links}, nil
 }
 
-// messageHandler using reflection
-func messageHandler(method string, parameters []byte) (proto.Message, error) {
-	// Get the reflect.Value of the serviceInstance
-	instanceValue := reflect.ValueOf(serviceInstance)
-
-	// Find the method by name
-	methodValue := instanceValue.MethodByName(method)
-	if !methodValue.IsValid() {
-		return nil, fmt.Errorf("MQ message called unknown method: %v", method)
+func (obj *testServiceImplementation) ListDeadLetters(ctx context.Context, _ *emptypb.Empty) (*pb.DeadLetters, error) {
+	if obj.JobQueue == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "durable async calls are disabled")
 	}
-
-	// Get the method type and number of inputs
-	methodType := methodValue.Type()
-	if methodType.NumIn() != 2 { // Ensure the method has exactly 2 inputs: context and proto.Message
-		return nil, fmt.Errorf("method %s has unexpected number of inputs", method)
+	res := &pb.DeadLetters{}
+	for _, deadLetter := range obj.JobQueue.DeadLetters() {
+		res.DeadLetters = append(res.DeadLetters, &pb.DeadLetter{
+			JobId:    deadLetter.JobID,
+			Method:   deadLetter.Method,
+			Attempts: int32(deadLetter.Attempts),
+			Error:    deadLetter.Error,
+		})
 	}
+	return res, nil
+}
 
-	// Determine the parameter type expected by the method
-	paramType := methodType.In(1).Elem()
-
-	// Create a new instance of the parameter type
-	paramInstance := reflect.New(paramType).Interface()
-
-	// Unmarshal parameters into the correct type
-	err := proto.Unmarshal(parameters, paramInstance.(proto.Message))
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal parameters: %v", err)
+func (obj *testServiceImplementation) ReplayDeadLetter(ctx context.Context, req *wrapperspb.StringValue) (*emptypb.Empty, error) {
+	if obj.JobQueue == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "durable async calls are disabled")
 	}
-
-	// Call the method with context and parameter instance
-	result := methodValue.Call([]reflect.Value{
-		reflect.ValueOf(context.Background()),
-		reflect.ValueOf(paramInstance),
-	})
-
-	// Extract the response and error from the result
-	response := result[0].Interface().(proto.Message)
-	errInterface := result[1].Interface()
-
-	if errInterface != nil {
-		return nil, errInterface.(error)
+	if err := obj.JobQueue.Replay(req.Value); err != nil {
+		return nil, status.Errorf(codes.NotFound, "%v", err)
 	}
-
-	return response, nil
+	return &emptypb.Empty{}, nil
 }